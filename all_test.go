@@ -1,11 +1,23 @@
 package neormgo
 
 import (
+	"context"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/Necoo33/neormgo/v2/expr"
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
 	"github.com/joho/godotenv"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
 )
 
 // change these variables to test your own database:
@@ -209,3 +221,1432 @@ func TestProcedureCall(t *testing.T) {
 	fmt.Printf("Here is your result of procedure call: %v\n", rows[0][resultAlias])
 
 }
+
+func TestPagination(t *testing.T) {
+	db := Neorm{_Driver: MicrosoftSqlServer}
+
+	query := db.Select("*")
+	query.Table(table)
+	query.Limit(10)
+	query.Offset(20)
+	query.Finish()
+
+	if query.Query != "SELECT * FROM users ORDER BY (SELECT NULL) OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY;" {
+		t.Fatalf("Unexpected pagination for microsoft sql server: %s", query.Query)
+	}
+
+	query = db.Select([]string{"id", "name"})
+	query.Table(table)
+	query.Limit(5)
+	query.Finish()
+
+	if query.Query != "SELECT TOP (5) id, name FROM users;" {
+		t.Fatalf("Unexpected top clause for microsoft sql server: %s", query.Query)
+	}
+
+	db = Neorm{_Driver: Sqlite3}
+
+	query = db.Select("*")
+	query.Table(table)
+	query.Offset(20)
+	query.Finish()
+
+	if query.Query != "SELECT * FROM users LIMIT -1 OFFSET 20;" {
+		t.Fatalf("Unexpected pagination for sqlite: %s", query.Query)
+	}
+
+	// the servers refuse these, so they shouldn't be rendered:
+	invalid := map[string]func(){
+		"offset on a mysql update": func() {
+			db := Neorm{_Driver: Mysql}
+			query := db.Update()
+			query.Table(table)
+			query.Set("vip", true)
+			query.Offset(5)
+			query.Finish()
+		},
+		"offset on a sqlite delete": func() {
+			db := Neorm{_Driver: Sqlite3}
+			query := db.Delete()
+			query.Table(table)
+			query.Offset(5)
+			query.Finish()
+		},
+		"limit on a postgresql update": func() {
+			db := Neorm{_Driver: Postgresql}
+			query := db.Update()
+			query.Table(table)
+			query.Set("vip", true)
+			query.Limit(5)
+			query.Finish()
+		},
+		"offset on a microsoft sql server update": func() {
+			db := Neorm{_Driver: MicrosoftSqlServer}
+			query := db.Update()
+			query.Table(table)
+			query.Set("vip", true)
+			query.Offset(5)
+			query.Finish()
+		},
+		"limit on a joined postgresql update": func() {
+			db := Neorm{_Driver: Postgresql}
+			query := db.Update()
+			query.Table(table)
+			query.InnerJoin("orders", "orders.user_id", "=", "users.id")
+			query.Set("vip", true)
+			query.Limit(5)
+			query.Finish()
+		},
+		"limit on a joined postgresql delete": func() {
+			db := Neorm{_Driver: Postgresql}
+			query := db.Delete()
+			query.Table(table)
+			query.InnerJoin("orders", "orders.user_id", "=", "users.id")
+			query.Limit(5)
+			query.Finish()
+		},
+		"limit on a joined mysql delete": func() {
+			db := Neorm{_Driver: Mysql}
+			query := db.Delete()
+			query.Table(table)
+			query.InnerJoin("orders", "orders.user_id", "=", "users.id")
+			query.Limit(5)
+			query.Finish()
+		},
+		"ordering on a joined postgresql update": func() {
+			db := Neorm{_Driver: Postgresql}
+			query := db.Update()
+			query.Table(table)
+			query.InnerJoin("orders", "orders.user_id", "=", "users.id")
+			query.Set("vip", true)
+			query.OrderBy("id", "ASC")
+			query.Finish()
+		},
+		"ordering on a joined microsoft sql server delete": func() {
+			db := Neorm{_Driver: MicrosoftSqlServer}
+			query := db.Delete()
+			query.Table(table)
+			query.InnerJoin("orders", "orders.user_id", "=", "users.id")
+			query.OrderBy("id", "ASC")
+			query.Finish()
+		},
+		"ordering on a joined mysql update": func() {
+			db := Neorm{_Driver: Mysql}
+			query := db.Update()
+			query.Table(table)
+			query.InnerJoin("orders", "orders.user_id", "=", "users.id")
+			query.Set("vip", true)
+			query.OrderBy("id", "ASC")
+			query.Finish()
+		},
+		"ordering on a joined mysql delete": func() {
+			db := Neorm{_Driver: Mysql}
+			query := db.Delete()
+			query.Table(table)
+			query.InnerJoin("orders", "orders.user_id", "=", "users.id")
+			query.OrderBy("id", "ASC")
+			query.Finish()
+		},
+		"returning on a joined mysql delete": func() {
+			db := Neorm{_Driver: Mysql}
+			query := db.Delete()
+			query.Table(table)
+			query.InnerJoin("orders", "orders.user_id", "=", "users.id")
+			query.Returning("id")
+			query.Finish()
+		},
+	}
+
+	for name, render := range invalid {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Rendering %s should panic", name)
+				}
+			}()
+
+			render()
+		}()
+	}
+}
+
+func TestOrdering(t *testing.T) {
+	db := Neorm{_Driver: Postgresql}
+
+	query := db.Select("*")
+	query.Table(table)
+	query.Where("age", ">", 18)
+	query.OrderByField("status", []string{"active", "passive"})
+	query.OrderRandom()
+	query.Finish()
+
+	if query.Query != "SELECT * FROM users WHERE age > $1 ORDER BY CASE WHEN status = $2 THEN 0 WHEN status = $3 THEN 1 ELSE 2 END, RANDOM();" {
+		t.Fatalf("Unexpected ordering for postgresql: %s", query.Query)
+	}
+
+	db = Neorm{_Driver: Mysql}
+
+	query = db.Select("*")
+	query.Table(table)
+	query.OrderByField("status", []string{"active", "passive"})
+	query.Finish()
+
+	// the values that aren't in the list go last on every driver:
+	if query.Query != "SELECT * FROM users ORDER BY CASE WHEN status = ? THEN 0 WHEN status = ? THEN 1 ELSE 2 END;" {
+		t.Fatalf("Unexpected field ordering for mysql: %s", query.Query)
+	}
+
+	db = Neorm{_Driver: MicrosoftSqlServer}
+
+	query = db.Select("*")
+	query.Table(table)
+	query.OrderByNulls("age", "asc", "last")
+	query.Finish()
+
+	if query.Query != "SELECT * FROM users ORDER BY CASE WHEN age IS NULL THEN 1 ELSE 0 END, age ASC;" {
+		t.Fatalf("Unexpected nulls ordering for microsoft sql server: %s", query.Query)
+	}
+}
+
+func TestReturning(t *testing.T) {
+	db := Neorm{_Driver: MicrosoftSqlServer}
+
+	query := db.Update()
+	query.Table(table)
+	query.Set("name", "john")
+	query.Where("id", "=", 1)
+	query.Returning("id", "name")
+	query.Finish()
+
+	if query.Query != "UPDATE users SET name = @p1 OUTPUT INSERTED.id, INSERTED.name WHERE id = @p2;" {
+		t.Fatalf("Unexpected output clause for microsoft sql server: %s", query.Query)
+	}
+
+	db = Neorm{_Driver: Sqlite3}
+
+	query = db.Delete()
+	query.Table(table)
+	query.Where("id", "=", 1)
+	query.Returning("*")
+	query.Finish()
+
+	if query.Query != "DELETE FROM users WHERE id = ? RETURNING *;" {
+		t.Fatalf("Unexpected returning clause for sqlite: %s", query.Query)
+	}
+}
+
+func TestDecodeValue(t *testing.T) {
+	db := Neorm{_Driver: Postgresql}
+
+	numbers, ok := db.decodeValue([]byte("{1,2,3}"), "_INT4").([]int64)
+	if !ok || len(numbers) != 3 || numbers[2] != 3 {
+		t.Fatalf("Unexpected decoding of integer array: %v", numbers)
+	}
+
+	document, ok := db.decodeValue([]byte(`{"address": {"city": "Istanbul"}}`), "JSONB").(map[string]interface{})
+	if !ok || document["address"].(map[string]interface{})["city"] != "Istanbul" {
+		t.Fatalf("Unexpected decoding of jsonb: %v", document)
+	}
+
+	prices, ok := db.decodeValue([]byte("{12345678901234567890.123456789,0.1}"), "_NUMERIC").([]string)
+	if !ok || len(prices) != 2 || prices[0] != "12345678901234567890.123456789" {
+		t.Fatalf("Numeric arrays shouldn't lose precision: %v", prices)
+	}
+
+	if raw := db.decodeValue([]byte("{a,NULL}"), "_INT4"); raw != "{a,NULL}" {
+		t.Fatalf("Undecodable arrays should stay as string: %v", raw)
+	}
+}
+
+func TestJSONQueries(t *testing.T) {
+	db := Neorm{_Driver: Postgresql}
+
+	query := db.Select([]string{"id"})
+	query.SelectJSON("data", "$.address.city", "city")
+	query.Table(table)
+	query.WhereJSON("data", "$.age", ">", 18)
+	query.JSONHasKey("and", "data", "tags")
+	query.Finish()
+
+	if query.Query != "SELECT id, data #>> $1 AS city FROM users WHERE (data #>> $2)::numeric > $3 AND data ? $4;" {
+		t.Fatalf("Unexpected json query for postgresql: %s", query.Query)
+	}
+
+	if len(query._Args) != 4 {
+		t.Fatalf("Unexpected count of arguments: %d", len(query._Args))
+	}
+
+	db = Neorm{_Driver: Mysql}
+
+	query = db.Select("*")
+	query.Table(table)
+	query.WhereJSON("data", "address.city", "=", "Istanbul")
+	query.JSONContains("or", "tags", []string{"go"})
+	query.Finish()
+
+	if query.Query != "SELECT * FROM users WHERE JSON_UNQUOTE(JSON_EXTRACT(data, ?)) = ? OR JSON_CONTAINS(tags, ?);" {
+		t.Fatalf("Unexpected json query for mysql: %s", query.Query)
+	}
+
+	if query._Args[0] != "$.address.city" || query._Args[2] != `["go"]` {
+		t.Fatalf("Unexpected arguments: %v", query._Args)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	db := Neorm{_Driver: Postgresql}
+
+	query := db.Select("*")
+	query.Table("blogs")
+	query.Where("published", "=", true)
+	query.Match([]string{"title", "description"}, "golang orm", "")
+	query.OrderByRelevance([]string{"title", "description"}, "golang orm", "")
+	query.Finish()
+
+	document := "to_tsvector('simple', coalesce(title, '') || ' ' || coalesce(description, ''))"
+	expected := fmt.Sprintf("SELECT * FROM blogs WHERE published = $1 AND %s @@ websearch_to_tsquery('simple', $2) ORDER BY ts_rank(%s, websearch_to_tsquery('simple', $3)) DESC;", document, document)
+
+	if query.Query != expected {
+		t.Fatalf("Unexpected full-text search for postgresql: %s", query.Query)
+	}
+
+	TextSearchConfiguration = "it's"
+	defer func() { TextSearchConfiguration = "simple" }()
+
+	index := db.CreateFulltextIndex("blogs_search", "blogs", []string{"title"})
+
+	if index.Query != "CREATE INDEX blogs_search ON blogs USING GIN (to_tsvector('it''s', coalesce(title, '')))" {
+		t.Fatalf("The text search configuration should be escaped: %s", index.Query)
+	}
+
+	db = Neorm{_Driver: Mysql}
+
+	query = db.Select("*")
+	query.Table("blogs")
+	query.Match([]string{"title"}, "+golang -java", "boolean")
+	query.Finish()
+
+	if query.Query != "SELECT * FROM blogs WHERE MATCH (title) AGAINST (? IN BOOLEAN MODE);" {
+		t.Fatalf("Unexpected full-text search for mysql: %s", query.Query)
+	}
+}
+
+func TestSqliteFulltextIndex(t *testing.T) {
+	db := Neorm{}
+
+	db, err := db.Connect(filepath.Join(t.TempDir(), "search.db"), "sqlite3")
+	if err != nil {
+		t.Fatalf("Connect failed: %s", err)
+	}
+	defer db.Close()
+
+	create := db.CustomQuery("CREATE TABLE blogs (id INTEGER PRIMARY KEY, title TEXT)")
+	if err := create.Execute(); err != nil {
+		t.Fatalf("Create table failed: %s", err)
+	}
+
+	insert := db.Insert([]string{"title"}, []interface{}{"golang orm"})
+	insert.Table("blogs")
+
+	if err := insert.Execute(); err != nil {
+		t.Fatalf("Insert failed: %s", err)
+	}
+
+	// fts5 is only built into go-sqlite3 with the sqlite_fts5 tag:
+	index := db.CreateFulltextIndex("blogs_search", "blogs", []string{"title"})
+	if err := index.Execute(); err != nil && strings.Contains(err.Error(), "no such module: fts5") {
+		t.Skip("sqlite is built without fts5")
+	} else if err != nil {
+		t.Fatalf("CreateFulltextIndex failed: %s", err)
+	}
+
+	insert = db.Insert([]string{"title"}, []interface{}{"rust web"})
+	insert.Table("blogs")
+
+	if err := insert.Execute(); err != nil {
+		t.Fatalf("Insert failed: %s", err)
+	}
+
+	update := db.Update()
+	update.Table("blogs")
+	update.Set("title", "golang web")
+	update.Where("id", "=", 2)
+
+	if err := update.Execute(); err != nil {
+		t.Fatalf("Update failed: %s", err)
+	}
+
+	search := func(term string) int64 {
+		query := db.Count("blogs_search")
+		query.Match([]string{"title"}, term, "")
+
+		if err := query.Execute(); err != nil {
+			t.Fatalf("Search failed: %s", err)
+		}
+
+		return query.Length()
+	}
+
+	if golang, rust := search("golang"), search("rust"); golang != 2 || rust != 0 {
+		t.Fatalf("The index should follow the rows of the table: %d %d", golang, rust)
+	}
+
+	remove := db.Delete()
+	remove.Table("blogs")
+	remove.Where("id", "=", 1)
+
+	if err := remove.Execute(); err != nil {
+		t.Fatalf("Delete failed: %s", err)
+	}
+
+	if golang := search("golang"); golang != 1 {
+		t.Fatalf("Deleted rows should be removed from the index: %d", golang)
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	cache := NewLRUCache(2)
+
+	cache.Set("first", []map[string]interface{}{{"id": 1}}, time.Minute, []string{"users"})
+	cache.Set("second", []map[string]interface{}{{"id": 2}}, time.Minute, []string{"blogs"})
+	cache.Get("first")
+	cache.Set("third", []map[string]interface{}{{"id": 3}}, time.Minute, []string{"users", "blogs"})
+
+	if _, ok := cache.Get("second"); ok {
+		t.Fatalf("Least recently used entry should be dropped.")
+	}
+
+	cache.Invalidate("users")
+
+	if _, ok := cache.Get("first"); ok {
+		t.Fatalf("Entries of an invalidated table should be dropped.")
+	}
+
+	if _, ok := cache.Get("third"); ok {
+		t.Fatalf("Entries of an invalidated table should be dropped.")
+	}
+
+	cache.Set("shared", []map[string]interface{}{{"id": 4}}, time.Minute, nil)
+
+	rows, _ := cache.Get("shared")
+	rows[0]["id"] = 5
+
+	if rows, _ := cache.Get("shared"); rows[0]["id"] != 4 {
+		t.Fatalf("Changing the returned rows shouldn't change the cache: %v", rows)
+	}
+
+	cache.Set("expired", nil, -time.Second, nil)
+
+	if _, ok := cache.Get("expired"); ok {
+		t.Fatalf("Expired entries shouldn't be returned.")
+	}
+}
+
+// invalidationStore records the tables that are invalidated.
+type invalidationStore struct {
+	*LRUCache
+	invalidated []string
+}
+
+func (store *invalidationStore) Invalidate(tag string) {
+	store.invalidated = append(store.invalidated, tag)
+	store.LRUCache.Invalidate(tag)
+}
+
+func TestCacheInTransaction(t *testing.T) {
+	db := Neorm{}
+
+	db, err := db.Connect(filepath.Join(t.TempDir(), "cache.db"), "sqlite3")
+	if err != nil {
+		t.Fatalf("Connect failed: %s", err)
+	}
+	defer db.Close()
+
+	store := &invalidationStore{LRUCache: NewLRUCache(10)}
+	db.CacheStore = store
+
+	create := db.CustomQuery("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	if err := create.Execute(); err != nil {
+		t.Fatalf("Create table failed: %s", err)
+	}
+
+	insert := func() {
+		query := db.Insert([]string{"name"}, []interface{}{"john"})
+		query.Table("users")
+
+		if err := query.Execute(); err != nil {
+			t.Fatalf("Insert failed: %s", err)
+		}
+	}
+
+	if err := db.Begin(); err != nil {
+		t.Fatalf("Begin failed: %s", err)
+	}
+
+	insert()
+
+	if len(store.invalidated) != 0 {
+		t.Fatalf("The cache shouldn't be invalidated before the transaction is committed: %v", store.invalidated)
+	}
+
+	if err := db.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %s", err)
+	}
+
+	if len(store.invalidated) != 0 {
+		t.Fatalf("The cache shouldn't be invalidated for a rolled back transaction: %v", store.invalidated)
+	}
+
+	if err := db.Begin(); err != nil {
+		t.Fatalf("Begin failed: %s", err)
+	}
+
+	insert()
+
+	if err := db.Commit(); err != nil {
+		t.Fatalf("Commit failed: %s", err)
+	}
+
+	if len(store.invalidated) != 1 || store.invalidated[0] != "users" {
+		t.Fatalf("The written tables should be invalidated on commit: %v", store.invalidated)
+	}
+}
+
+func TestCacheOfCustomQueries(t *testing.T) {
+	db := Neorm{}
+
+	db, err := db.Connect(filepath.Join(t.TempDir(), "cache.db"), "sqlite3")
+	if err != nil {
+		t.Fatalf("Connect failed: %s", err)
+	}
+	defer db.Close()
+
+	db.CacheStore = NewLRUCache(10)
+
+	for _, query := range []string{"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)", "INSERT INTO users (name) VALUES ('john')"} {
+		custom := db.CustomQuery(query)
+		if err := custom.Execute(); err != nil {
+			t.Fatalf("Preparing the table failed: %s", err)
+		}
+	}
+
+	name := func() interface{} {
+		query := db.CustomSelectQuery("SELECT name FROM (SELECT * FROM users) AS u WHERE id = ?", 1)
+		query.Cache(time.Minute, "users")
+
+		if err := query.Execute(); err != nil {
+			t.Fatalf("Select failed: %s", err)
+		}
+
+		return query._Rows[0]["name"]
+	}
+
+	if got := name(); got != "john" {
+		t.Fatalf("Unexpected name: %v", got)
+	}
+
+	update := db.CustomUpdateQuery("UPDATE users SET name = ? WHERE id = ?", "jane", 1)
+	update.Invalidates("users")
+
+	if err := update.Execute(); err != nil {
+		t.Fatalf("Update failed: %s", err)
+	}
+
+	if got := name(); got != "jane" {
+		t.Fatalf("A custom write should drop the cached rows of a custom read: %v", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Caching a custom query without it's tables should panic")
+		}
+	}()
+
+	query := db.CustomSelectQuery("SELECT * FROM users")
+	query.Cache(time.Minute)
+}
+
+func TestImmutableQuery(t *testing.T) {
+	db := &DB{base: Neorm{_Driver: Postgresql}}
+
+	base := db.Select("*").Table(table).Where("age", ">", 18).Limit(10)
+
+	var wg sync.WaitGroup
+	queries := make([]Query, 20)
+
+	for i := range queries {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			queries[i] = base.And("name", "=", fmt.Sprintf("user-%d", i))
+		}(i)
+	}
+
+	wg.Wait()
+
+	rendered := base.with(func(orm *Neorm) { orm.Finish() })
+	if len(rendered.orm._Args) != 1 || rendered.orm.Query != "SELECT * FROM users WHERE age > $1 LIMIT 10;" {
+		t.Fatalf("Base query shouldn't be changed: %s %v", rendered.orm.Query, rendered.orm._Args)
+	}
+
+	for i, query := range queries {
+		query = query.with(func(orm *Neorm) { orm.Finish() })
+
+		if query.orm._Args[1] != fmt.Sprintf("user-%d", i) || query.orm.Query != "SELECT * FROM users WHERE age > $1 AND name = $2 LIMIT 10;" {
+			t.Fatalf("Queries built from the same base shouldn't share arguments: %s %v", query.orm.Query, query.orm._Args)
+		}
+	}
+}
+
+func TestStatementOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  func() Neorm
+		driver Driver
+		want   string
+	}{
+		{"where before table", func() Neorm {
+			orm := Neorm{_Driver: Postgresql}
+			orm.Select("*")
+			orm.Where("age", ">", 18)
+			orm.OrderBy("name", "ASC")
+			orm.Table(table)
+			return orm.Finish()
+		}, Postgresql, "SELECT * FROM users WHERE age > $1 ORDER BY name ASC;"},
+		{"limit before order", func() Neorm {
+			orm := Neorm{_Driver: MicrosoftSqlServer}
+			orm.Select([]string{"id", "name"})
+			orm.Limit(5)
+			orm.OrderBy("id", "DESC")
+			orm.Where("age", ">", 18)
+			orm.Table(table)
+			return orm.Finish()
+		}, MicrosoftSqlServer, "SELECT TOP (5) id, name FROM users WHERE age > @p1 ORDER BY id DESC;"},
+		{"join after where", func() Neorm {
+			orm := Neorm{_Driver: Mysql}
+			orm.Select("*")
+			orm.Table(table)
+			orm.Where("users.age", ">", 18)
+			orm.InnerJoin("orders", "orders.user_id", "=", "users.id")
+			orm.GroupBy("users.id")
+			return orm.Finish()
+		}, Mysql, "SELECT * FROM users INNER JOIN orders ON orders.user_id = users.id WHERE users.age > ? GROUP BY users.id;"},
+		{"set after where", func() Neorm {
+			orm := Neorm{_Driver: Postgresql}
+			orm.Update()
+			orm.Where("id", "=", 1)
+			orm.Set("name", "john")
+			orm.Table(table)
+			return orm.Finish()
+		}, Postgresql, "UPDATE users SET name = $1 WHERE id = $2;"},
+		{"create table", func() Neorm {
+			orm := Neorm{_Driver: Mysql}
+			orm.CreateTable(table)
+			orm = orm.IfNotExist()
+			orm.AddColumn("id")
+			orm.Type("int")
+			orm.PrimaryKey()
+			orm.AddColumn("name")
+			orm.Type("varchar(50)")
+			orm.NotNull()
+			orm.Engine("InnoDB")
+			return orm.Finish()
+		}, Mysql, "CREATE TABLE IF NOT EXISTS users (id INT PRIMARY KEY, name VARCHAR(50) NOT NULL) ENGINE = InnoDB;"},
+	}
+
+	for _, test := range tests {
+		if got := test.query().Query; got != test.want {
+			t.Errorf("%s: expected %q, got %q", test.name, test.want, got)
+		}
+	}
+}
+
+func TestResult(t *testing.T) {
+	db := Neorm{_Driver: Postgresql, _Count: -1}
+
+	db._Rows = []map[string]interface{}{{"id": int64(7)}}
+	db._ReturnsRows = true
+	db._LastInsertIdForPostgresql = "7"
+
+	result := db.result()
+
+	if result.Rows != nil || len(result.Returned) != 1 || result.RowsAffected != 1 || result.LastInsertId != "7" {
+		t.Fatalf("Unexpected result of a returning query: %+v", result)
+	}
+
+	db._ReturnsRows = false
+	db._LastInsertIdForPostgresql = ""
+
+	result = db.result()
+
+	if len(result.Rows) != 1 || result.Returned != nil || result.RowsAffected != 0 || result.LastInsertId != "" {
+		t.Fatalf("Unexpected result of a select query: %+v", result)
+	}
+
+	db = Neorm{_Driver: MicrosoftSqlServer, _Count: -1}
+
+	db._Result = affectedRows(1)
+	db._LastInsertIdForPostgresql = "12"
+
+	result = db.result()
+
+	if result.RowsAffected != 1 || result.LastInsertId != "12" {
+		t.Fatalf("Unexpected result of an insert on microsoft sql server: %+v", result)
+	}
+}
+
+func TestInsertId(t *testing.T) {
+	db := Neorm{_Driver: MicrosoftSqlServer}
+
+	query := db.Insert([]string{"name"}, []interface{}{"john"})
+	query.Table(table)
+	query.Finish()
+
+	if query.Query != "INSERT INTO users (name) VALUES (@p1);" {
+		t.Fatalf("Unexpected insert for microsoft sql server: %s", query.Query)
+	}
+
+	// the identity is only selected on execution:
+	if got := query.SQL(); strings.Contains(got, "SCOPE_IDENTITY") {
+		t.Fatalf("Unexpected interpolated insert for microsoft sql server: %s", got)
+	}
+
+	if !query.selectsInsertId() {
+		t.Fatalf("Inserts of microsoft sql server should select their ids")
+	}
+
+	db = Neorm{_Driver: Postgresql}
+
+	query = db.Insert([]string{"name"}, []interface{}{"john"})
+	query.Table(table)
+	query.Finish()
+
+	if query.selectsInsertId() {
+		t.Fatalf("Inserts of postgresql without a returning clause should be executed")
+	}
+
+	query = db.CustomInsertQuery("INSERT INTO users (name) VALUES (?) RETURNING id", "john")
+	query.Finish()
+
+	if !query.selectsInsertId() {
+		t.Fatalf("Custom inserts of postgresql with a returning clause should select their ids")
+	}
+}
+
+func TestRowLocking(t *testing.T) {
+	db := Neorm{_Driver: Postgresql}
+
+	query := db.Select("*")
+	query.Table("jobs")
+	query.ForUpdate()
+	query.SkipLocked()
+	query.Where("status", "=", "ready")
+	query.Limit(1)
+	query.Finish()
+
+	if query.Query != "SELECT * FROM jobs WHERE status = $1 LIMIT 1 FOR UPDATE SKIP LOCKED;" {
+		t.Fatalf("Unexpected locking clause for postgresql: %s", query.Query)
+	}
+
+	if err := query.Execute(); err == nil {
+		t.Fatalf("A locking query shouldn't be executed outside of a transaction")
+	}
+
+	db = Neorm{_Driver: MicrosoftSqlServer}
+
+	query = db.Select("*")
+	query.Table("jobs")
+	query.InnerJoin("queues", "queues.id", "=", "jobs.queue_id")
+	query.ForUpdate()
+	query.SkipLocked()
+	query.Of("jobs")
+	query.Limit(1)
+	query.Finish()
+
+	if query.Query != "SELECT TOP (1) * FROM jobs WITH (UPDLOCK, ROWLOCK, READPAST) INNER JOIN queues ON queues.id = jobs.queue_id;" {
+		t.Fatalf("Unexpected table hints for microsoft sql server: %s", query.Query)
+	}
+
+	db = Neorm{_Driver: Sqlite3}
+
+	query = db.Select("*")
+	query.Table("jobs")
+	query.ForShare()
+	query.NoWait()
+	query.Finish()
+
+	if query.Query != "SELECT * FROM jobs;" {
+		t.Fatalf("Locking clauses should be ignored on sqlite: %s", query.Query)
+	}
+}
+
+func TestLockOnSqlite(t *testing.T) {
+	db := Neorm{}
+
+	db, err := db.Connect(filepath.Join(t.TempDir(), "lock.db")+"?_busy_timeout=5000", "sqlite3")
+	if err != nil {
+		t.Fatalf("Connect failed: %s", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	lock, err := db.Lock(ctx, "nightly-report")
+	if err != nil {
+		t.Fatalf("Lock failed: %s", err)
+	}
+
+	if _, acquired, err := db.TryLock(ctx, "nightly-report"); err != nil || acquired {
+		t.Fatalf("A held lock shouldn't be acquired again: %v %v", acquired, err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 150*time.Millisecond)
+	defer cancel()
+
+	if _, err := db.Lock(waitCtx, "nightly-report"); err == nil {
+		t.Fatalf("Lock should wait until ctx is done")
+	}
+
+	if err := lock.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock failed: %s", err)
+	}
+
+	again, acquired, err := db.TryLock(ctx, "nightly-report")
+	if err != nil || !acquired {
+		t.Fatalf("A released lock should be acquired: %v %v", acquired, err)
+	}
+
+	again.Unlock(ctx)
+}
+
+func TestFailedUnlock(t *testing.T) {
+	db := Neorm{}
+
+	db, err := db.Connect(filepath.Join(t.TempDir(), "lock.db")+"?_busy_timeout=5000", "sqlite3")
+	if err != nil {
+		t.Fatalf("Connect failed: %s", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	lock, acquired, err := db.TryLock(ctx, "nightly-report")
+	if err != nil || !acquired {
+		t.Fatalf("TryLock failed: %v %v", acquired, err)
+	}
+
+	drop := db.CustomQuery("DROP TABLE " + LockTable)
+	if err := drop.Execute(); err != nil {
+		t.Fatalf("Drop table failed: %s", err)
+	}
+
+	open := db.Pool.Stats().OpenConnections
+
+	if err := lock.Unlock(ctx); err == nil {
+		t.Fatalf("Unlock should fail without the lock table")
+	}
+
+	if stats := db.Pool.Stats(); stats.OpenConnections != open-1 {
+		t.Fatalf("The connection of a failed unlock shouldn't be pooled again: %d of %d", stats.OpenConnections, open)
+	}
+
+	again, acquired, err := db.TryLock(ctx, "nightly-report")
+	if err != nil || !acquired {
+		t.Fatalf("The lock should be acquired on a fresh connection: %v %v", acquired, err)
+	}
+
+	again.Unlock(ctx)
+}
+
+func TestInsertFrom(t *testing.T) {
+	db := Neorm{_Driver: Postgresql}
+
+	old := db.Select([]string{"id", "name"})
+	old.Table(table)
+	old.Where("created_at", "<", "2020-01-01")
+
+	query := db.Update()
+	query.InsertFrom("users_archive", []string{"id", "name"}, old)
+	query.Returning("id")
+	query.Finish()
+
+	if query.Query != "INSERT INTO users_archive (id, name) SELECT id, name FROM users WHERE created_at < $1 RETURNING id;" || query._Args[0] != "2020-01-01" {
+		t.Fatalf("Unexpected insert from select for postgresql: %s %v", query.Query, query._Args)
+	}
+
+	adults := db.Select("*")
+	adults.Where("age", ">=", 18)
+	adults.Table(table)
+
+	query = db.Update()
+	query.CreateTableAs("adults", adults)
+	query.Finish()
+
+	if query.Query != "CREATE TABLE adults AS SELECT * FROM users WHERE age >= $1;" {
+		t.Fatalf("Unexpected create table as for postgresql: %s", query.Query)
+	}
+
+	db = Neorm{_Driver: MicrosoftSqlServer}
+
+	adults = db.Select([]string{"id", "name"})
+	adults.Table(table)
+	adults.Where("age", ">=", 18)
+
+	query = db.Update()
+	query.CreateTableAs("adults", adults)
+	query.Finish()
+
+	if query.Query != "SELECT id, name INTO adults FROM users WHERE age >= @p1;" {
+		t.Fatalf("Unexpected select into for microsoft sql server: %s", query.Query)
+	}
+}
+
+func TestJoinedUpdateAndDelete(t *testing.T) {
+	tests := []struct {
+		driver Driver
+		delete bool
+		want   string
+	}{
+		{Mysql, false, "UPDATE users INNER JOIN orders ON orders.user_id = users.id SET vip = ? WHERE orders.total > ? OR users.age > ?;"},
+		{Postgresql, false, "UPDATE users SET vip = $1 FROM orders WHERE orders.user_id = users.id AND (orders.total > $2 OR users.age > $3);"},
+		{Sqlite3, false, "UPDATE users SET vip = ? FROM orders WHERE orders.user_id = users.id AND (orders.total > ? OR users.age > ?);"},
+		{MicrosoftSqlServer, false, "UPDATE users SET vip = @p1 FROM users INNER JOIN orders ON orders.user_id = users.id WHERE orders.total > @p2 OR users.age > @p3;"},
+		{Mysql, true, "DELETE users FROM users INNER JOIN orders ON orders.user_id = users.id WHERE orders.total > ? OR users.age > ?;"},
+		{Postgresql, true, "DELETE FROM users USING orders WHERE orders.user_id = users.id AND (orders.total > $1 OR users.age > $2);"},
+		{Sqlite3, true, "DELETE FROM users WHERE rowid IN (SELECT users.rowid FROM users INNER JOIN orders ON orders.user_id = users.id WHERE orders.total > ? OR users.age > ?);"},
+		{MicrosoftSqlServer, true, "DELETE users FROM users INNER JOIN orders ON orders.user_id = users.id WHERE orders.total > @p1 OR users.age > @p2;"},
+	}
+
+	for _, test := range tests {
+		db := Neorm{_Driver: test.driver}
+
+		var query Neorm
+		if test.delete {
+			query = db.Delete()
+		} else {
+			query = db.Update()
+			query.Set("vip", true)
+		}
+
+		query.Table(table)
+		query.InnerJoin("orders", "orders.user_id", "=", "users.id")
+		query.Where("orders.total", ">", 100)
+		query.Or("users.age", ">", 65)
+		query.Finish()
+
+		if query.Query != test.want {
+			t.Errorf("Unexpected joined query for driver %d:\n%s\n%s", test.driver, query.Query, test.want)
+		}
+	}
+}
+
+func TestExpressions(t *testing.T) {
+	db := Neorm{_Driver: MicrosoftSqlServer}
+
+	query := db.Select([]interface{}{"id", expr.As(expr.Concat(expr.Col("first_name"), " ", expr.Col("last_name")), "full_name")})
+	query.Table(table)
+	query.Where("age", ">", 18)
+	query.Condition("AND", expr.Gt(expr.Coalesce(expr.Col("discount"), 0), 10))
+	query.OrderByExpr(expr.Case().When(expr.Eq(expr.Col("status"), "vip"), 0).Else(1), "asc")
+	query.Finish()
+
+	want := "SELECT id, (first_name + @p1 + last_name) AS full_name FROM users WHERE age > @p2 AND (COALESCE(discount, @p3) > @p4) " +
+		"ORDER BY CASE WHEN (status = @p5) THEN @p6 ELSE @p7 END ASC;"
+
+	if query.Query != want || len(query._Args) != 7 || query._Args[0] != " " || query._Args[4] != "vip" {
+		t.Fatalf("Unexpected query with expressions: %s %v", query.Query, query._Args)
+	}
+
+	db = Neorm{_Driver: Postgresql}
+
+	query = db.Update()
+	query.Table(table)
+	query.Set("visits", expr.Add(expr.Col("visits"), 1))
+	query.Where("id", "=", 3)
+	query.Finish()
+
+	if query.Query != "UPDATE users SET visits = (visits + $1) WHERE id = $2;" {
+		t.Fatalf("Unexpected update with an expression: %s", query.Query)
+	}
+}
+
+func TestTimeBucketOnSqlite(t *testing.T) {
+	db := Neorm{}
+
+	db, err := db.Connect(filepath.Join(t.TempDir(), "buckets.db"), "sqlite3")
+	if err != nil {
+		t.Fatalf("Connect failed: %s", err)
+	}
+	defer db.Close()
+
+	create := db.CustomQuery("CREATE TABLE orders (id INTEGER PRIMARY KEY, paid_at TEXT)")
+	if err := create.Execute(); err != nil {
+		t.Fatalf("Create table failed: %s", err)
+	}
+
+	// 2024-08-18 is a sunday, so it belongs to the week of 2024-08-12:
+	for _, paidAt := range []string{"2024-08-12 09:00:00", "2024-08-18 23:59:59", "2024-08-19 00:00:00"} {
+		insert := db.Insert([]string{"paid_at"}, []interface{}{paidAt})
+		insert.Table("orders")
+
+		if err := insert.Execute(); err != nil {
+			t.Fatalf("Insert failed: %s", err)
+		}
+	}
+
+	query := db.Select([]interface{}{expr.As(expr.DateTrunc(expr.Week, expr.Col("paid_at")), "week"), "COUNT(*) AS orders"})
+	query.Table("orders")
+	query.GroupByTimeBucket("paid_at", expr.Week)
+	query.OrderBy("week", "ASC")
+
+	result, err := query.ExecuteResult()
+	if err != nil {
+		t.Fatalf("Select failed: %s", err)
+	}
+
+	if len(result.Rows) != 2 || result.Rows[0]["week"] != "2024-08-12 00:00:00" || result.Rows[0]["orders"] != int64(2) ||
+		result.Rows[1]["week"] != "2024-08-19 00:00:00" || result.Rows[1]["orders"] != int64(1) {
+		t.Fatalf("Unexpected weekly buckets: %v", result.Rows)
+	}
+}
+
+func TestNamedParams(t *testing.T) {
+	db := Neorm{_Driver: Postgresql}
+
+	query := db.CustomSelectQuery("SELECT * FROM users WHERE id = :id AND status IN (:statuses) AND created_at::date > :since "+
+		"AND note <> ':id' -- :missing\nAND age > :id", Params{"id": 1, "statuses": []string{"active", "vip"}, "since": "2024-01-01"})
+	query.Finish()
+
+	want := "SELECT * FROM users WHERE id = $1 AND status IN ($2, $3) AND created_at::date > $4 AND note <> ':id' -- :missing\nAND age > $5;"
+
+	if query.Query != want || len(query._Args) != 5 || query._Args[2] != "vip" || query._Args[4] != 1 {
+		t.Fatalf("Unexpected query with named params: %s %v", query.Query, query._Args)
+	}
+
+	type base struct {
+		ID int `db:"id"`
+	}
+
+	type user struct {
+		base
+		Name     string `db:"name,omitempty"`
+		Password string `db:"-"`
+		Age      int
+	}
+
+	db = Neorm{_Driver: MicrosoftSqlServer}
+
+	query = db.CustomUpdateQuery("UPDATE users SET name = :name, age = :age WHERE id = :id AND role IN (:roles)", &user{base{7}, "john", "secret", 30}, Params{"roles": []int{1, 2}})
+	query.Finish()
+
+	if query.Query != "UPDATE users SET name = @p1, age = @p2 WHERE id = @p3 AND role IN (@p4, @p5);" || query._Args[0] != "john" || query._Args[2] != 7 {
+		t.Fatalf("Unexpected query with struct params: %s %v", query.Query, query._Args)
+	}
+
+	invalid := map[string]func(){
+		"a missing parameter": func() {
+			db.CustomSelectQuery("SELECT * FROM users WHERE id = :id", Params{"name": "john"})
+		},
+		"an empty slice in IN": func() {
+			db.CustomSelectQuery("SELECT * FROM users WHERE role IN (:roles)", Params{"roles": []int{}})
+		},
+		"an empty slice in NOT IN": func() {
+			db.CustomSelectQuery("SELECT * FROM users WHERE role NOT IN (:roles)", Params{"roles": []string{}})
+		},
+	}
+
+	for name, build := range invalid {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Binding %s should panic", name)
+				}
+			}()
+
+			build()
+		}()
+	}
+}
+
+func TestCustomQueryClauses(t *testing.T) {
+	db := Neorm{_Driver: Postgresql}
+
+	tests := map[string]string{
+		"SELECT * FROM (SELECT * FROM users WHERE age > 18) adults": "SELECT * FROM (SELECT * FROM users WHERE age > 18) adults WHERE vip = $1;",
+		"SELECT * FROM users\nWHERE age > 18":                       "SELECT * FROM users\nWHERE age > 18 AND vip = $1;",
+		"SELECT 'where' AS note, \"where\" FROM users -- WHERE\n":   "SELECT 'where' AS note, \"where\" FROM users -- WHERE\n WHERE vip = $1;",
+		"SELECT * FROM users\twhere age > 18":                       "SELECT * FROM users\twhere age > 18 AND vip = $1;",
+	}
+
+	for custom, want := range tests {
+		query := db.CustomSelectQuery(custom)
+		query.Where("vip", "=", true)
+		query.Finish()
+
+		if query.Query != want {
+			t.Errorf("Unexpected condition on a custom query:\n%s\n%s", query.Query, want)
+		}
+	}
+
+	db = Neorm{_Driver: MicrosoftSqlServer}
+
+	query := db.CustomSelectQuery("SELECT * FROM users\norder  by age")
+	query.Offset(20)
+	query.Finish()
+
+	if query.Query != "SELECT * FROM users\norder  by age OFFSET 20 ROWS;" {
+		t.Fatalf("The ORDER BY of a custom query should be kept: %s", query.Query)
+	}
+
+	query = db.CustomSelectQuery("SELECT * FROM (SELECT TOP 5 * FROM users ORDER BY age) youngest")
+	query.OrderBy("name", "ASC")
+	query.Finish()
+
+	if query.Query != "SELECT * FROM (SELECT TOP 5 * FROM users ORDER BY age) youngest ORDER BY name ASC;" {
+		t.Fatalf("The ORDER BY of a subquery shouldn't be taken as the query's own: %s", query.Query)
+	}
+}
+
+func TestRebind(t *testing.T) {
+	query := "SELECT * FROM users WHERE id = ? AND data ?? 'admin' AND note <> '?' /* ? */ AND name = ?"
+
+	tests := map[Driver]string{
+		Postgresql:         "SELECT * FROM users WHERE id = $1 AND data ? 'admin' AND note <> '?' /* ? */ AND name = $2",
+		MicrosoftSqlServer: "SELECT * FROM users WHERE id = @p1 AND data ? 'admin' AND note <> '?' /* ? */ AND name = @p2",
+		Sqlite3:            "SELECT * FROM users WHERE id = ? AND data ? 'admin' AND note <> '?' /* ? */ AND name = ?",
+	}
+
+	for driver, want := range tests {
+		db := Neorm{_Driver: driver}
+
+		if got := db.Rebind(query); got != want {
+			t.Errorf("Unexpected rebind for driver %d: %s", driver, got)
+		}
+	}
+
+	db := Neorm{_Driver: Postgresql}
+
+	custom := db.CustomSelectQuery("SELECT * FROM users WHERE tags ?? 'vip' AND id = ? AND ids = ANY(?)", 3, []int{1, 2})
+	custom.Limit(10)
+	custom.Finish()
+
+	if custom.Query != "SELECT * FROM users WHERE tags ? 'vip' AND id = $1 AND ids = ANY($2) LIMIT 10;" || len(custom._Args) != 2 {
+		t.Fatalf("Unexpected custom query with positional params: %s %v", custom.Query, custom._Args)
+	}
+
+	custom = db.CustomSelectQuery("SELECT * FROM users WHERE tags ?? 'vip'")
+	custom.Finish()
+
+	if custom.Query != "SELECT * FROM users WHERE tags ? 'vip';" || len(custom._Args) != 0 {
+		t.Fatalf("Escaped question marks should be written without params: %s", custom.Query)
+	}
+
+	// the queries without params aren't bound, the literals of postgresql are skipped when they're bound:
+	kept := map[string]string{
+		"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1 WHERE 'a' ? 'b' $$ LANGUAGE sql": "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1 WHERE 'a' ? 'b' $$ LANGUAGE sql;",
+		"SELECT tags[1:n] FROM users WHERE id = ?":                                       "SELECT tags[1:n] FROM users WHERE id = ?;",
+	}
+
+	for query, want := range kept {
+		custom = db.CustomSelectQuery(query)
+		custom.Finish()
+
+		if custom.Query != want {
+			t.Errorf("A custom query without params should be kept: %s", custom.Query)
+		}
+	}
+
+	custom = db.CustomSelectQuery("SELECT $body$ ? :id $body$, E'it\\'s ?', tags[1:n], tags[:n] FROM users WHERE id = :id", Params{"id": 3})
+	custom.Finish()
+
+	if custom.Query != "SELECT $body$ ? :id $body$, E'it\\'s ?', tags[1:n], tags[:n] FROM users WHERE id = $1;" || len(custom._Args) != 1 {
+		t.Fatalf("Unexpected custom query with postgresql literals: %s %v", custom.Query, custom._Args)
+	}
+
+	invalid := map[string]func(){
+		"too many values": func() {
+			db.CustomDeleteQuery("DELETE FROM users WHERE id = ?", 1, 2)
+		},
+	}
+
+	for name, build := range invalid {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Binding %s should panic", name)
+				}
+			}()
+
+			build()
+		}()
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	db := Neorm{_Driver: Postgresql}
+
+	ids := make([]interface{}, 10)
+	for i := range ids {
+		ids[i] = i + 1
+	}
+
+	query := db.Select("*")
+	query.Table(table)
+	query.Where("name", "=", "O'Reilly")
+	query.In("AND", "id", ids)
+	query.Where("avatar", "=", []byte{0xca, 0xfe})
+	query.Where("created_at", ">", time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC))
+	query.Where("tags", "=", []string{"a", "b"})
+
+	want := "SELECT * FROM users WHERE name = 'O''Reilly' AND id IN(1, 2, 3, 4, 5, 6, 7, 8, 9, 10) AND avatar = '\\xcafe'::bytea " +
+		"AND created_at > '2024-03-01 12:30:00+00:00' AND tags = '{\"a\",\"b\"}'"
+
+	if got := query.SQL(); got != want {
+		t.Fatalf("Unexpected interpolated query for postgresql: %s", got)
+	}
+
+	// SQL shouldn't change the builder:
+	query.Finish()
+
+	if !strings.HasSuffix(query.Query, "AND tags = $14;") || len(query._Args) != 14 {
+		t.Fatalf("The query should still be rendered with placeholders: %s", query.Query)
+	}
+
+	if got := query.GetFullQuery(); got != want+";" || query.Query != "" {
+		t.Fatalf("Unexpected full query: %s", got)
+	}
+
+	if got := db.Interpolate("SELECT $1, $$x$1$$", 5); got != "SELECT 5, $$x$1$$" {
+		t.Fatalf("Placeholders in dollar quoted strings shouldn't be interpolated: %s", got)
+	}
+
+	db = Neorm{_Driver: MicrosoftSqlServer}
+
+	if got := db.Interpolate("UPDATE users SET active = @p1, name = @p2 WHERE note = '@p1' AND id = @p3", true, "Çağrı", nil); got !=
+		"UPDATE users SET active = 1, name = N'Çağrı' WHERE note = '@p1' AND id = NULL" {
+		t.Fatalf("Unexpected interpolated query for microsoft sql server: %s", got)
+	}
+
+	db = Neorm{_Driver: Mysql}
+
+	if got := db.Interpolate("SELECT ? AS path, ? AS data, '?' AS mark, ? AS paid", `C:\temp\it's`, []byte("hi"), 2.5); got !=
+		`SELECT 'C:\\temp\\it''s' AS path, X'6869' AS data, '?' AS mark, 2.5 AS paid` {
+		t.Fatalf("Unexpected interpolated query for mysql: %s", got)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	db := Neorm{}
+
+	db, err := db.Connect(filepath.Join(t.TempDir(), "errors.db")+"?_foreign_keys=1", "sqlite3")
+	if err != nil {
+		t.Fatalf("Connect failed: %s", err)
+	}
+	defer db.Close()
+
+	for _, query := range []string{
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL UNIQUE, age INTEGER CONSTRAINT age_positive CHECK (age > 0))",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users (id))",
+		"INSERT INTO users (id, email, age) VALUES (1, 'john@example.com', 30)",
+	} {
+		custom := db.CustomQuery(query)
+
+		if err := custom.Execute(); err != nil {
+			t.Fatalf("Setup failed: %s", err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  ErrorInfo
+	}{
+		{"INSERT INTO users (email, age) VALUES ('john@example.com', 20)", ErrorInfo{Kind: UniqueViolation, Code: "2067", Table: "users", Column: "email"}},
+		{"INSERT INTO users (email, age) VALUES (NULL, 20)", ErrorInfo{Kind: NotNullViolation, Code: "1299", Table: "users", Column: "email"}},
+		{"INSERT INTO users (email, age) VALUES ('jane@example.com', -1)", ErrorInfo{Kind: CheckViolation, Code: "275", Constraint: "age_positive"}},
+		{"INSERT INTO orders (user_id) VALUES (42)", ErrorInfo{Kind: ForeignKeyViolation, Code: "787"}},
+	}
+
+	for _, test := range tests {
+		custom := db.CustomQuery(test.query)
+		err := custom.Execute()
+
+		if got := ClassifyError(err); got != test.want {
+			t.Errorf("Unexpected classification of %q: %+v", err, got)
+		}
+	}
+
+	mysqlErr := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'john@example.com' for key 'users.email_unique'"}
+
+	if got := ClassifyError(fmt.Errorf("insert failed: %w", mysqlErr)); got != (ErrorInfo{Kind: UniqueViolation, Code: "1062", Table: "users", Constraint: "email_unique"}) {
+		t.Errorf("Unexpected classification of a wrapped mysql error: %+v", got)
+	}
+
+	mssqlErr := mssql.Error{Number: 547, Message: `The DELETE statement conflicted with the REFERENCE constraint "FK_orders_users". The conflict occurred in database "shop", table "dbo.orders", column 'user_id'.`}
+
+	if got := ClassifyError(mssqlErr); got != (ErrorInfo{Kind: ForeignKeyViolation, Code: "547", Constraint: "FK_orders_users", Table: "dbo.orders", Column: "user_id"}) {
+		t.Errorf("Unexpected classification of a microsoft sql server error: %+v", got)
+	}
+
+	if !IsDeadlock(&pq.Error{Code: "40P01"}) || !IsConnectionLost(driver.ErrBadConn) || IsConnectionLost(context.DeadlineExceeded) || ClassifyError(nil).Kind != UnknownError {
+		t.Errorf("Unexpected classification of the other errors")
+	}
+}
+
+func TestQueryError(t *testing.T) {
+	db := Neorm{}
+
+	db, err := db.Connect(filepath.Join(t.TempDir(), "query_error.db"), "sqlite3")
+	if err != nil {
+		t.Fatalf("Connect failed: %s", err)
+	}
+	defer db.Close()
+
+	query := db.Select("*")
+	query.Table("missing_table")
+	query.Where("id", "=", 1)
+
+	err = query.Execute()
+
+	var queryErr *QueryError
+	if !errors.As(err, &queryErr) {
+		t.Fatalf("Execute should give back a QueryError: %#v", err)
+	}
+
+	if queryErr.Query != "SELECT * FROM missing_table WHERE id = ?" || queryErr.Args != 1 || queryErr.Driver != Sqlite3 || queryErr.Operation != "select" {
+		t.Fatalf("Unexpected query error: %+v", queryErr)
+	}
+
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || !strings.Contains(err.Error(), "no such table: missing_table") || !strings.Contains(err.Error(), queryErr.Query) {
+		t.Fatalf("The error of the driver should be kept: %s", err)
+	}
+
+	// CustomQuery keeps the execution type of the select before it:
+	custom := query.CustomQuery("DELETE FROM missing_table")
+
+	if err := custom.Execute(); !errors.As(err, &queryErr) || queryErr.Operation != "exec" {
+		t.Fatalf("Unexpected operation of a custom query: %v", err)
+	}
+
+	alter := query.AlterTable("missing_table")
+	alter.DropColumn("id")
+
+	if err := alter.Execute(); !errors.As(err, &queryErr) || queryErr.Operation != "ddl" {
+		t.Fatalf("Unexpected operation of a ddl query: %v", err)
+	}
+
+	pqErr := &pq.Error{Code: "42601", Message: `syntax error at or near "FORM"`, Position: "10"}
+
+	if got := newQueryError(pqErr, "SELECT * FORM users", 0, Postgresql, "select"); got.Position != 10 ||
+		got.Error() != `select query failed at character 10: pq: syntax error at or near "FORM"; query: SELECT * FORM users; args: 0` {
+		t.Fatalf("Unexpected query error for postgresql: %s", got)
+	}
+}
+
+func TestCopyFromSources(t *testing.T) {
+	db := Neorm{_Driver: Mysql}
+
+	if _, err := db.CopyFrom(context.Background(), "users", []string{"name"}, [][]any{{"john"}}); err == nil || err.Error() != "CopyFrom is only supported on postgresql" {
+		t.Fatalf("CopyFrom should be refused on mysql: %v", err)
+	}
+
+	db = Neorm{_Driver: Postgresql}
+
+	if _, err := db.CopyFrom(context.Background(), "users", []string{"name"}, []string{"john"}); err == nil || !strings.HasPrefix(err.Error(), "source should be") {
+		t.Fatalf("An unsupported source should be refused: %v", err)
+	}
+
+	var rows [][]any
+	collect := func(row []any) error {
+		rows = append(rows, row)
+
+		return nil
+	}
+
+	err := eachRow(context.Background(), strings.NewReader("john,,30\n\"jane\",\"\",\n"), 3, collect)
+
+	if err != nil || len(rows) != 2 || rows[0][0] != "john" || rows[0][1] != nil || rows[0][2] != "30" || rows[1][1] != nil || rows[1][2] != nil {
+		t.Fatalf("Empty csv fields should be NULL: %v %v", rows, err)
+	}
+
+	if err := eachRow(context.Background(), [][]any{{"john", 30}, {"jane"}}, 2, collect); err == nil || err.Error() != "row 2 has 1 values but 2 columns are given" {
+		t.Fatalf("A row without a value for each column should be refused: %v", err)
+	}
+
+	values := make(chan []any, 2)
+	values <- []any{"john", 30, "extra"}
+	values <- []any{"jane", 28}
+
+	if err := eachRow(context.Background(), values, 2, collect); err == nil || err.Error() != "row 1 has 3 values but 2 columns are given" {
+		t.Fatalf("A row of a channel without a value for each column should be refused: %v", err)
+	}
+
+	if len(values) != 1 {
+		t.Fatalf("A channel shouldn't be read after copying fails")
+	}
+
+	// a channel that is never closed is left once ctx is done:
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := eachRow(ctx, make(chan []any), 2, collect); err != context.Canceled {
+		t.Fatalf("Reading a channel should stop when ctx is done: %v", err)
+	}
+}
+
+func TestBulkInsertBatches(t *testing.T) {
+	db := Neorm{_Driver: Postgresql}
+
+	if _, err := db.BulkInsert("users", []string{"name"}, [][]any{{"john"}}, BulkOptions{}); err == nil || err.Error() != "BulkInsert is only supported on microsoft sql server" {
+		t.Fatalf("BulkInsert should be refused on postgresql: %v", err)
+	}
+
+	db = Neorm{_Driver: MicrosoftSqlServer}
+
+	if _, err := db.BulkInsert("users", []string{"name", "age"}, [][]any{{"john", 30}, {"jane"}}, BulkOptions{}); err == nil ||
+		err.Error() != "row 2 has 1 values but 2 columns are given" {
+		t.Fatalf("A row without a value for each column should be refused before sending: %v", err)
+	}
+
+	rows := [][]any{{1}, {2}, {3}, {4}, {5}}
+
+	if split := batches(rows, 2); len(split) != 3 || len(split[0]) != 2 || len(split[2]) != 1 || split[2][0][0] != 5 {
+		t.Fatalf("Unexpected batches: %v", split)
+	}
+
+	if split := batches(rows, 0); len(split) != 1 || len(split[0]) != 5 {
+		t.Fatalf("A batch size of zero should keep the rows in one batch: %v", split)
+	}
+}
+
+func TestListenWithoutServer(t *testing.T) {
+	db := Neorm{_Driver: Mysql}
+
+	if _, err := db.Listen(context.Background(), "jobs"); err == nil || err.Error() != "Listen is only supported on postgresql" {
+		t.Fatalf("Listen should be refused on mysql: %v", err)
+	}
+
+	db = Neorm{_Driver: Postgresql, _ConnString: "postgres://neorm@127.0.0.1:1/neorm?sslmode=disable&connect_timeout=1"}
+
+	if _, err := db.Listen(context.Background()); err == nil || err.Error() != "channels cannot be empty" {
+		t.Fatalf("Listen should be refused without channels: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	started := time.Now()
+
+	if _, err := db.Listen(ctx, "jobs"); err == nil || time.Since(started) > 4*time.Second {
+		t.Fatalf("Listen should give back the error of an unreachable server: %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := db.Listen(cancelled, "jobs"); err == nil {
+		t.Fatalf("Listen should stop when ctx is done")
+	}
+}
@@ -0,0 +1,335 @@
+package neormgo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/lib/pq"
+)
+
+// bulk loading:
+
+// BulkOptions are the options of microsoft sql server bulk copy, BatchSize is the count of rows that are sent in
+// each bulk copy, zero means all rows are sent in one.
+type BulkOptions struct {
+	KeepNulls         bool
+	Tablock           bool
+	CheckConstraints  bool
+	FireTriggers      bool
+	BatchSize         int
+	KilobytesPerBatch int
+	Order             []string
+}
+
+// CopyFrom loads rows into a postgresql table with "COPY FROM STDIN". source can be a [][]any, a channel of []any
+// (copying ends when the channel is closed) or an io.Reader of csv records, whose empty fields are NULL. It runs
+// inside the current transaction if there is one, otherwise it opens and commits it's own. It returns the count of
+// copied rows.
+//
+// A channel isn't read anymore once copying fails or ctx is done, so the goroutine that sends to it should also
+// select on ctx.Done() and ctx should be cancelled after CopyFrom returns, otherwise it blocks forever.
+func (orm *Neorm) CopyFrom(ctx context.Context, table string, columns []string, source interface{}) (int64, error) {
+	if orm._Driver != Postgresql {
+		return 0, fmt.Errorf("CopyFrom is only supported on postgresql")
+	}
+
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("columns cannot be empty")
+	}
+
+	if err := checkSource(source); err != nil {
+		return 0, err
+	}
+
+	tx := orm.Tx
+	ownTx := tx == nil
+
+	if ownTx {
+		if orm.Pool == nil {
+			return 0, fmt.Errorf("database connection not initialized")
+		}
+
+		var err error
+		tx, err = orm.Pool.BeginTx(ctx, nil)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	copied, err := copyIn(ctx, tx, table, columns, source)
+
+	if err != nil {
+		if ownTx {
+			tx.Rollback()
+		}
+
+		return copied, err
+	}
+
+	if ownTx {
+		if err := tx.Commit(); err != nil {
+			return 0, err
+		}
+	}
+
+	orm.invalidateCache(table)
+
+	return copied, nil
+}
+
+func copyIn(ctx context.Context, tx *sql.Tx, table string, columns []string, source interface{}) (int64, error) {
+	var query string
+
+	if schema, name, found := strings.Cut(table, "."); found {
+		query = pq.CopyInSchema(schema, name, columns...)
+	} else {
+		query = pq.CopyIn(table, columns...)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var copied int64
+
+	err = eachRow(ctx, source, len(columns), func(row []any) error {
+		args := make([]any, len(row))
+		for i, value := range row {
+			switch value.(type) {
+			case []string, []int, []int8, []int16, []int32, []int64,
+				[]uint, []uint16, []uint32, []uint64,
+				[]float32, []float64, []bool, []any:
+				args[i] = pq.Array(value)
+			default:
+				args[i] = value
+			}
+		}
+
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return err
+		}
+
+		copied++
+
+		return nil
+	})
+
+	if err != nil {
+		return copied, err
+	}
+
+	// an exec without arguments flushes the buffered rows and ends the copy:
+	result, err := stmt.ExecContext(ctx)
+	if err != nil {
+		return copied, err
+	}
+
+	if ra, err := result.RowsAffected(); err == nil && ra > 0 {
+		copied = ra
+	}
+
+	return copied, nil
+}
+
+// eachRow gives the rows of a CopyFrom source to fn and checks that they have a value for each column. Empty csv
+// fields are NULL just like they're in "COPY ... CSV", quoted empty strings can't be told apart from them.
+func eachRow(ctx context.Context, source interface{}, columns int, fn func(row []any) error) error {
+	count := 0
+
+	each := func(row []any) error {
+		count++
+
+		if len(row) != columns {
+			return fmt.Errorf("row %d has %d values but %d columns are given", count, len(row), columns)
+		}
+
+		return fn(row)
+	}
+
+	switch t := source.(type) {
+	case [][]any:
+		for _, row := range t {
+			if err := each(row); err != nil {
+				return err
+			}
+		}
+	case chan []any:
+		return eachReceived(ctx, t, each)
+	case <-chan []any:
+		return eachReceived(ctx, t, each)
+	case io.Reader:
+		reader := csv.NewReader(t)
+		reader.FieldsPerRecord = columns
+
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				return err
+			}
+
+			row := make([]any, len(record))
+			for i, field := range record {
+				if field != "" {
+					row[i] = field
+				}
+			}
+
+			if err := each(row); err != nil {
+				return err
+			}
+		}
+	default:
+		return checkSource(source)
+	}
+
+	return nil
+}
+
+// eachReceived reads a channel until it's closed, ctx is done or fn fails.
+func eachReceived(ctx context.Context, rows <-chan []any, fn func(row []any) error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case row, ok := <-rows:
+			if !ok {
+				return nil
+			}
+
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func checkSource(source interface{}) error {
+	switch source.(type) {
+	case [][]any, chan []any, <-chan []any, io.Reader:
+		return nil
+	default:
+		return fmt.Errorf("source should be either [][]any, a channel of []any or an io.Reader, got %T", source)
+	}
+}
+
+// BulkInsert loads rows into a microsoft sql server table with bulk copy. It runs inside the current transaction
+// if there is one. Each BatchSize rows are sent as a separate bulk copy, so outside of a transaction the batches that
+// are sent before an error stay inserted. It returns the count of inserted rows.
+func (orm *Neorm) BulkInsert(table string, columns []string, rows [][]any, options BulkOptions) (int64, error) {
+	if orm._Driver != MicrosoftSqlServer {
+		return 0, fmt.Errorf("BulkInsert is only supported on microsoft sql server")
+	}
+
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("columns cannot be empty")
+	}
+
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return 0, fmt.Errorf("row %d has %d values but %d columns are given", i+1, len(row), len(columns))
+		}
+	}
+
+	ctx := context.Background()
+
+	prepare := func(query string) (*sql.Stmt, error) {
+		return orm.Tx.PrepareContext(ctx, query)
+	}
+
+	if orm.Tx == nil {
+		if orm.Pool == nil {
+			return 0, fmt.Errorf("database connection not initialized")
+		}
+
+		conn, err := orm.Pool.Conn(ctx)
+		if err != nil {
+			return 0, err
+		}
+		defer conn.Close()
+
+		prepare = func(query string) (*sql.Stmt, error) {
+			return conn.PrepareContext(ctx, query)
+		}
+	}
+
+	var inserted int64
+
+	for _, batch := range batches(rows, options.BatchSize) {
+		query := mssql.CopyIn(table, mssql.BulkOptions{
+			KeepNulls:         options.KeepNulls,
+			Tablock:           options.Tablock,
+			CheckConstraints:  options.CheckConstraints,
+			FireTriggers:      options.FireTriggers,
+			RowsPerBatch:      len(batch),
+			KilobytesPerBatch: options.KilobytesPerBatch,
+			Order:             options.Order,
+		}, columns...)
+
+		count, err := bulkCopy(ctx, prepare, query, batch)
+		inserted += count
+
+		if err != nil {
+			if inserted > 0 {
+				orm.invalidateCache(table)
+			}
+
+			return inserted, err
+		}
+	}
+
+	orm.invalidateCache(table)
+
+	return inserted, nil
+}
+
+// batches splits rows into the batches of the given size, a size of zero keeps them in one batch.
+func batches(rows [][]any, size int) [][][]any {
+	if size <= 0 || size >= len(rows) {
+		if len(rows) == 0 {
+			return nil
+		}
+
+		return [][][]any{rows}
+	}
+
+	var split [][][]any
+
+	for start := 0; start < len(rows); start += size {
+		split = append(split, rows[start:min(start+size, len(rows))])
+	}
+
+	return split
+}
+
+func bulkCopy(ctx context.Context, prepare func(query string) (*sql.Stmt, error), query string, rows [][]any) (int64, error) {
+	stmt, err := prepare(query)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return 0, err
+		}
+	}
+
+	// an exec without arguments sends the buffered rows to the server:
+	result, err := stmt.ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,240 @@
+package neormgo
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// query result cache:
+
+// CacheStore keeps the rows of cached select queries. Entries are tagged with the tables they're read from,
+// Invalidate is called with a table when an insert, update or delete query runs against it.
+type CacheStore interface {
+	Get(key string) ([]map[string]interface{}, bool)
+	Set(key string, rows []map[string]interface{}, ttl time.Duration, tags []string)
+	Invalidate(tag string)
+}
+
+type lruEntry struct {
+	key     string
+	rows    []map[string]interface{}
+	expires time.Time
+	tags    []string
+}
+
+// LRUCache is an in-memory CacheStore that drops the least recently used entries when it's full.
+// It's safe for concurrent use, the rows are copied on Set and Get so the callers can change them.
+type LRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+	tags     map[string]map[string]struct{}
+}
+
+func NewLRUCache(capacity int) *LRUCache {
+	if capacity <= 0 {
+		panic("Capacity of the cache should be bigger than zero.")
+	}
+
+	return &LRUCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+		tags:     map[string]map[string]struct{}{},
+	}
+}
+
+func (cache *LRUCache) Get(key string) ([]map[string]interface{}, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*lruEntry)
+
+	if time.Now().After(entry.expires) {
+		cache.remove(element)
+
+		return nil, false
+	}
+
+	cache.order.MoveToFront(element)
+
+	return copyRows(entry.rows), true
+}
+
+func (cache *LRUCache) Set(key string, rows []map[string]interface{}, ttl time.Duration, tags []string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[key]; ok {
+		cache.remove(element)
+	}
+
+	entry := &lruEntry{key: key, rows: copyRows(rows), expires: time.Now().Add(ttl), tags: tags}
+	cache.entries[key] = cache.order.PushFront(entry)
+
+	for _, tag := range tags {
+		if cache.tags[tag] == nil {
+			cache.tags[tag] = map[string]struct{}{}
+		}
+
+		cache.tags[tag][key] = struct{}{}
+	}
+
+	for cache.order.Len() > cache.capacity {
+		cache.remove(cache.order.Back())
+	}
+}
+
+func (cache *LRUCache) Invalidate(tag string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	for key := range cache.tags[tag] {
+		if element, ok := cache.entries[key]; ok {
+			cache.remove(element)
+		}
+	}
+
+	delete(cache.tags, tag)
+}
+
+func (cache *LRUCache) remove(element *list.Element) {
+	entry := element.Value.(*lruEntry)
+
+	cache.order.Remove(element)
+	delete(cache.entries, entry.key)
+
+	for _, tag := range entry.tags {
+		delete(cache.tags[tag], entry.key)
+
+		if len(cache.tags[tag]) == 0 {
+			delete(cache.tags, tag)
+		}
+	}
+}
+
+func copyRows(rows []map[string]interface{}) []map[string]interface{} {
+	if rows == nil {
+		return nil
+	}
+
+	copied := make([]map[string]interface{}, len(rows))
+
+	for i, row := range rows {
+		copied[i] = make(map[string]interface{}, len(row))
+
+		for column, value := range row {
+			if b, ok := value.([]byte); ok {
+				value = append([]byte(nil), b...)
+			}
+
+			copied[i][column] = value
+		}
+	}
+
+	return copied
+}
+
+// Cache makes the rows of a select query to be kept in the CacheStore of the instance for ttl. The key is the
+// rendered query with it's arguments, queries inside a transaction always go to the database. The rows are
+// dropped when the tables of the query are written to, tables adds the ones that the builder doesn't know, such as
+// the tables of a subquery. They can't be known from the text of a custom query, so they should be given for it.
+func (orm *Neorm) Cache(ttl time.Duration, tables ...string) Neorm {
+	if orm._Type != "s" {
+		panic("Cache can only be used with select queries.")
+	}
+
+	st := orm.statement()
+
+	if st.kind == "raw" && len(tables) == 0 {
+		panic("Error on Cache method: the tables of a custom query should be given.")
+	}
+
+	st.tags = append(st.tags, tables...)
+	orm._CacheTTL = ttl
+
+	return *orm
+}
+
+// Invalidates gives the tables that a custom query writes to, the cached rows of them are dropped once it's
+// executed. The builder queries drop the rows of their own table.
+func (orm *Neorm) Invalidates(tables ...string) Neorm {
+	st := orm.statement()
+	st.invalidates = append(st.invalidates, tables...)
+
+	return *orm
+}
+
+func (orm *Neorm) cacheKey() string {
+	var key strings.Builder
+
+	key.WriteString(orm.Query)
+
+	for _, arg := range orm._Args {
+		fmt.Fprintf(&key, "\x00%#v", arg)
+	}
+
+	return key.String()
+}
+
+func (orm *Neorm) cacheTags() []string {
+	var tags []string
+
+	if orm._Statement.table != "" {
+		tags = append(tags, orm._Statement.table)
+	}
+
+	for _, join := range orm._Statement.joins {
+		tags = append(tags, join.table)
+	}
+
+	tags = append(tags, orm._Statement.tags...)
+
+	return tags
+}
+
+// txTables are the tables that a transaction has written to. The other connections don't see the changes until
+// it's committed, so their entries are invalidated on Commit and they're forgotten on Rollback.
+type txTables struct {
+	mu     sync.Mutex
+	tables []string
+}
+
+func (orm *Neorm) invalidateCache(table string) {
+	if orm.CacheStore == nil || table == "" {
+		return
+	}
+
+	if orm.Tx != nil && orm._TxTables != nil {
+		orm._TxTables.mu.Lock()
+		orm._TxTables.tables = append(orm._TxTables.tables, table)
+		orm._TxTables.mu.Unlock()
+
+		return
+	}
+
+	orm.CacheStore.Invalidate(table)
+}
+
+// invalidateCommitted invalidates the tables that a committed transaction has written to.
+func (orm *Neorm) invalidateCommitted(written *txTables) {
+	if orm.CacheStore == nil || written == nil {
+		return
+	}
+
+	written.mu.Lock()
+	defer written.mu.Unlock()
+
+	for _, table := range written.tables {
+		orm.CacheStore.Invalidate(table)
+	}
+}
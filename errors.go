@@ -0,0 +1,347 @@
+package neormgo
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// error classification:
+
+// ErrorKind is the kind of a database error, it's the same on every driver.
+type ErrorKind int
+
+const (
+	UnknownError ErrorKind = iota
+	UniqueViolation
+	ForeignKeyViolation
+	NotNullViolation
+	CheckViolation
+	Deadlock
+	SerializationFailure
+	LockTimeout
+	ConnectionLost
+)
+
+func (kind ErrorKind) String() string {
+	switch kind {
+	case UniqueViolation:
+		return "unique violation"
+	case ForeignKeyViolation:
+		return "foreign key violation"
+	case NotNullViolation:
+		return "not null violation"
+	case CheckViolation:
+		return "check violation"
+	case Deadlock:
+		return "deadlock"
+	case SerializationFailure:
+		return "serialization failure"
+	case LockTimeout:
+		return "lock timeout"
+	case ConnectionLost:
+		return "connection lost"
+	default:
+		return "unknown error"
+	}
+}
+
+// ErrorInfo is what is known about a database error. Code is the error code of the driver, such as "23505" on
+// postgresql and "1062" on mysql. Constraint, Table and Column are empty if the driver doesn't tell them; they're
+// taken from the fields of the error on postgresql and from it's message on the others.
+type ErrorInfo struct {
+	Kind       ErrorKind
+	Code       string
+	Constraint string
+	Table      string
+	Column     string
+}
+
+// ClassifyError finds the kind of a database error of any driver, errors that wrap them are classified too.
+func ClassifyError(err error) ErrorInfo {
+	if err == nil {
+		return ErrorInfo{}
+	}
+
+	var pqErr *pq.Error
+	var mysqlErr *mysql.MySQLError
+	var sqliteErr sqlite3.Error
+	var mssqlErr mssql.Error
+	var netErr *net.OpError
+
+	switch {
+	case errors.As(err, &pqErr):
+		return classifyPostgresql(pqErr)
+	case errors.As(err, &mysqlErr):
+		return classifyMysql(mysqlErr)
+	case errors.As(err, &sqliteErr):
+		return classifySqlite(sqliteErr)
+	case errors.As(err, &mssqlErr):
+		return classifyMicrosoftSqlServer(mssqlErr)
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone), errors.Is(err, mysql.ErrInvalidConn), errors.As(err, &netErr):
+		return ErrorInfo{Kind: ConnectionLost}
+	}
+
+	return ErrorInfo{}
+}
+
+func IsUniqueViolation(err error) bool {
+	return ClassifyError(err).Kind == UniqueViolation
+}
+
+func IsForeignKeyViolation(err error) bool {
+	return ClassifyError(err).Kind == ForeignKeyViolation
+}
+
+func IsNotNullViolation(err error) bool {
+	return ClassifyError(err).Kind == NotNullViolation
+}
+
+func IsCheckViolation(err error) bool {
+	return ClassifyError(err).Kind == CheckViolation
+}
+
+func IsDeadlock(err error) bool {
+	return ClassifyError(err).Kind == Deadlock
+}
+
+func IsSerializationFailure(err error) bool {
+	return ClassifyError(err).Kind == SerializationFailure
+}
+
+func IsLockTimeout(err error) bool {
+	return ClassifyError(err).Kind == LockTimeout
+}
+
+func IsConnectionLost(err error) bool {
+	return ClassifyError(err).Kind == ConnectionLost
+}
+
+func classifyPostgresql(err *pq.Error) ErrorInfo {
+	info := ErrorInfo{Code: string(err.Code), Constraint: err.Constraint, Table: err.Table, Column: err.Column}
+
+	switch {
+	case err.Code == "23505":
+		info.Kind = UniqueViolation
+	case err.Code == "23503":
+		info.Kind = ForeignKeyViolation
+	case err.Code == "23502":
+		info.Kind = NotNullViolation
+	case err.Code == "23514":
+		info.Kind = CheckViolation
+	case err.Code == "40P01":
+		info.Kind = Deadlock
+	case err.Code == "40001":
+		info.Kind = SerializationFailure
+	case err.Code == "55P03":
+		info.Kind = LockTimeout
+	case err.Code.Class() == "08", err.Code == "57P01", err.Code == "57P02", err.Code == "57P03":
+		info.Kind = ConnectionLost
+	}
+
+	return info
+}
+
+var (
+	mysqlDuplicateKey = regexp.MustCompile("for key '([^']+)'")
+	mysqlForeignKey   = regexp.MustCompile("`([^`]+)`, CONSTRAINT `([^`]+)` FOREIGN KEY \\(`([^`]+)`")
+	mysqlQuotedName   = regexp.MustCompile("^(?:Column|Field|Check constraint) '([^']+)'")
+)
+
+func classifyMysql(err *mysql.MySQLError) ErrorInfo {
+	info := ErrorInfo{Code: strconv.Itoa(int(err.Number))}
+
+	switch err.Number {
+	case 1062, 1586:
+		info.Kind = UniqueViolation
+
+		// the key is written as "table.key" since mysql 8:
+		if match := mysqlDuplicateKey.FindStringSubmatch(err.Message); match != nil {
+			if table, key, ok := strings.Cut(match[1], "."); ok {
+				info.Table, info.Constraint = table, key
+			} else {
+				info.Constraint = match[1]
+			}
+		}
+	case 1216, 1217, 1451, 1452:
+		info.Kind = ForeignKeyViolation
+
+		if match := mysqlForeignKey.FindStringSubmatch(err.Message); match != nil {
+			info.Table, info.Constraint, info.Column = match[1], match[2], match[3]
+		}
+	case 1048, 1364:
+		info.Kind = NotNullViolation
+
+		if match := mysqlQuotedName.FindStringSubmatch(err.Message); match != nil {
+			info.Column = match[1]
+		}
+	case 3819, 4025:
+		info.Kind = CheckViolation
+
+		if match := mysqlQuotedName.FindStringSubmatch(err.Message); match != nil {
+			info.Constraint = match[1]
+		}
+	case 1213:
+		info.Kind = Deadlock
+	case 1205:
+		info.Kind = LockTimeout
+	case 1040, 1053, 1927, 2002, 2003, 2006, 2013, 4031:
+		info.Kind = ConnectionLost
+	}
+
+	return info
+}
+
+func classifySqlite(err sqlite3.Error) ErrorInfo {
+	info := ErrorInfo{Code: strconv.Itoa(int(err.ExtendedCode))}
+
+	// the messages are like "UNIQUE constraint failed: users.email, users.name":
+	_, detail, _ := strings.Cut(err.Error(), "constraint failed: ")
+
+	switch err.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		info.Kind = UniqueViolation
+		info.Table, info.Column = sqliteColumns(detail)
+	case sqlite3.ErrConstraintForeignKey:
+		info.Kind = ForeignKeyViolation
+	case sqlite3.ErrConstraintNotNull:
+		info.Kind = NotNullViolation
+		info.Table, info.Column = sqliteColumns(detail)
+	case sqlite3.ErrConstraintCheck:
+		info.Kind = CheckViolation
+		info.Constraint = detail
+	default:
+		switch err.Code {
+		case sqlite3.ErrBusy, sqlite3.ErrLocked:
+			info.Kind = LockTimeout
+		case sqlite3.ErrCantOpen:
+			info.Kind = ConnectionLost
+		}
+	}
+
+	return info
+}
+
+// sqliteColumns splits "users.email, users.name" into the table and "email, name".
+func sqliteColumns(detail string) (string, string) {
+	table := ""
+	columns := strings.Split(detail, ", ")
+
+	for i, column := range columns {
+		if t, c, ok := strings.Cut(column, "."); ok {
+			table, columns[i] = t, c
+		}
+	}
+
+	return table, strings.Join(columns, ", ")
+}
+
+var (
+	mssqlConstraint = regexp.MustCompile(`(?:constraint|index) ["']([^"']+)["']`)
+	mssqlTable      = regexp.MustCompile(`(?:object|table) ["']([^"']+)["']`)
+	mssqlColumn     = regexp.MustCompile(`column '([^']+)'`)
+)
+
+func classifyMicrosoftSqlServer(err mssql.Error) ErrorInfo {
+	info := ErrorInfo{Code: strconv.Itoa(int(err.Number))}
+
+	switch err.Number {
+	case 2627, 2601:
+		info.Kind = UniqueViolation
+	case 547:
+		// foreign key and check constraints share the same number:
+		if strings.Contains(err.Message, "CHECK constraint") {
+			info.Kind = CheckViolation
+		} else {
+			info.Kind = ForeignKeyViolation
+		}
+	case 515:
+		info.Kind = NotNullViolation
+	case 1205:
+		info.Kind = Deadlock
+	case 3960:
+		info.Kind = SerializationFailure
+	case 1222:
+		info.Kind = LockTimeout
+	case 233, 10053, 10054:
+		info.Kind = ConnectionLost
+	}
+
+	if info.Kind != UnknownError && info.Kind != ConnectionLost {
+		if match := mssqlConstraint.FindStringSubmatch(err.Message); match != nil {
+			info.Constraint = match[1]
+		}
+
+		if match := mssqlTable.FindStringSubmatch(err.Message); match != nil {
+			info.Table = match[1]
+		}
+
+		if match := mssqlColumn.FindStringSubmatch(err.Message); match != nil {
+			info.Column = match[1]
+		}
+	}
+
+	return info
+}
+
+// query errors:
+
+// QueryError is an error of an executed query. Err is the error of the driver, it can be reached with errors.As and
+// errors.Is. Args is the number of the bound values, which are left out since they can be sensitive. Operation is
+// "select", "count", "insert", "update", "delete" or "call" for the queries of the builder, "ddl" for the schema and
+// table builders and "exec" for the custom queries. Position is the character of the query that postgresql points
+// to, it's 0 on the other drivers.
+type QueryError struct {
+	Err       error
+	Query     string
+	Args      int
+	Driver    Driver
+	Operation string
+	Position  int
+}
+
+// newQueryError names the operation by the kind of the statement, since the execution type of a custom query is
+// kept from the query before it.
+func newQueryError(err error, query string, args int, driver Driver, kind string) *QueryError {
+	queryErr := &QueryError{Err: err, Query: query, Args: args, Driver: driver}
+
+	switch kind {
+	case "select", "count", "insert", "update", "delete", "call":
+		queryErr.Operation = kind
+	case "insertSelect":
+		queryErr.Operation = "insert"
+	case "createSchema", "createTable", "alterTable", "createTableAs", "ddl":
+		queryErr.Operation = "ddl"
+	default:
+		queryErr.Operation = "exec"
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		queryErr.Position, _ = strconv.Atoi(pqErr.Position)
+	}
+
+	return queryErr
+}
+
+func (e *QueryError) Error() string {
+	if e.Position > 0 {
+		return fmt.Sprintf("%s query failed at character %d: %s; query: %s; args: %d", e.Operation, e.Position, e.Err, e.Query, e.Args)
+	}
+
+	return fmt.Sprintf("%s query failed: %s; query: %s; args: %d", e.Operation, e.Err, e.Query, e.Args)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,352 @@
+// Package expr builds sql expressions that keep their values bound as parameters and are rendered with the syntax
+// of the driver, such as CASE, COALESCE, string concatenation and arithmetic. They can be given to the builder
+// methods of neormgo as values, columns and conditions.
+//
+// Operands that are not expressions are values: Eq(Col("status"), "active") binds "active" as a parameter,
+// columns should be given with Col.
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect is the sql dialect that an expression is rendered in, it's in the same order with neormgo.Driver.
+type Dialect int
+
+const (
+	Mysql Dialect = iota
+	Postgresql
+	Sqlite3
+	MicrosoftSqlServer
+)
+
+// Expr is an sql expression.
+type Expr interface {
+	Build(b *Builder)
+}
+
+// Builder collects the sql text and the bound values of an expression. The values go between the texts,
+// the caller writes the placeholders of them.
+type Builder struct {
+	Dialect Dialect
+	texts   []string
+	args    []any
+}
+
+func NewBuilder(dialect Dialect) *Builder {
+	return &Builder{Dialect: dialect, texts: []string{""}}
+}
+
+// Write appends sql text.
+func (b *Builder) Write(sql string) {
+	b.texts[len(b.texts)-1] += sql
+}
+
+// Bind appends a bound value.
+func (b *Builder) Bind(value any) {
+	b.args = append(b.args, value)
+	b.texts = append(b.texts, "")
+}
+
+// Operand writes an expression, or binds anything else as a value. nil is written as NULL.
+func (b *Builder) Operand(operand any) {
+	switch t := operand.(type) {
+	case Expr:
+		t.Build(b)
+	case nil:
+		b.Write("NULL")
+	default:
+		b.Bind(t)
+	}
+}
+
+func (b *Builder) operands(operands []any, separator string) {
+	for i, operand := range operands {
+		if i != 0 {
+			b.Write(separator)
+		}
+
+		b.Operand(operand)
+	}
+}
+
+// Parts gives the texts and the values that go between them, texts has one more element than values.
+func (b *Builder) Parts() ([]string, []any) {
+	return b.texts, b.args
+}
+
+// Render writes the expression with "?" placeholders, it's mostly for debugging.
+func Render(e Expr, dialect Dialect) (string, []any) {
+	b := NewBuilder(dialect)
+	e.Build(b)
+
+	return strings.Join(b.texts, "?"), b.args
+}
+
+type exprFunc func(b *Builder)
+
+func (f exprFunc) Build(b *Builder) {
+	f(b)
+}
+
+// Col is a column, such as Col("users.name").
+func Col(name string) Expr {
+	return exprFunc(func(b *Builder) { b.Write(name) })
+}
+
+// Val is a bound value, it's only needed where a value would be taken as something else.
+func Val(value any) Expr {
+	return exprFunc(func(b *Builder) {
+		if value == nil {
+			b.Write("NULL")
+		} else {
+			b.Bind(value)
+		}
+	})
+}
+
+// Raw is sql that is written as is.
+func Raw(sql string) Expr {
+	return exprFunc(func(b *Builder) { b.Write(sql) })
+}
+
+// As gives an alias to an expression, for the selected columns.
+func As(e Expr, alias string) Expr {
+	return exprFunc(func(b *Builder) {
+		e.Build(b)
+		b.Write(" AS " + alias)
+	})
+}
+
+// function writes name(operands...).
+func function(name string, operands ...any) Expr {
+	return exprFunc(func(b *Builder) {
+		b.Write(name + "(")
+		b.operands(operands, ", ")
+		b.Write(")")
+	})
+}
+
+func Coalesce(operands ...any) Expr {
+	if len(operands) == 0 {
+		panic("Error on Coalesce function: operands cannot be empty.")
+	}
+
+	return function("COALESCE", operands...)
+}
+
+func Lower(operand any) Expr {
+	return function("LOWER", operand)
+}
+
+func Upper(operand any) Expr {
+	return function("UPPER", operand)
+}
+
+// Concat joins strings: "||" on postgresql and sqlite, CONCAT() on mysql and "+" on microsoft sql server.
+func Concat(operands ...any) Expr {
+	if len(operands) == 0 {
+		panic("Error on Concat function: operands cannot be empty.")
+	}
+
+	return exprFunc(func(b *Builder) {
+		switch b.Dialect {
+		case Mysql:
+			function("CONCAT", operands...).Build(b)
+		case MicrosoftSqlServer:
+			b.Write("(")
+			b.operands(operands, " + ")
+			b.Write(")")
+		default:
+			b.Write("(")
+			b.operands(operands, " || ")
+			b.Write(")")
+		}
+	})
+}
+
+// Cast converts an operand to the given type. On mysql, which can only cast to a few types, the text and integer
+// types are turned into CHAR and SIGNED.
+func Cast(operand any, typeName string) Expr {
+	return exprFunc(func(b *Builder) {
+		target := typeName
+
+		if b.Dialect == Mysql {
+			switch strings.ToUpper(strings.Split(typeName, "(")[0]) {
+			case "TEXT", "VARCHAR":
+				target = "CHAR"
+			case "INT", "INTEGER", "BIGINT", "SMALLINT":
+				target = "SIGNED"
+			}
+		}
+
+		b.Write("CAST(")
+		b.Operand(operand)
+		b.Write(fmt.Sprintf(" AS %s)", target))
+	})
+}
+
+// binary writes (left operator right).
+func binary(left any, operator string, right any) Expr {
+	return exprFunc(func(b *Builder) {
+		b.Write("(")
+		b.Operand(left)
+		b.Write(fmt.Sprintf(" %s ", operator))
+		b.Operand(right)
+		b.Write(")")
+	})
+}
+
+// arithmetic:
+
+func Add(left, right any) Expr {
+	return binary(left, "+", right)
+}
+
+func Sub(left, right any) Expr {
+	return binary(left, "-", right)
+}
+
+func Mul(left, right any) Expr {
+	return binary(left, "*", right)
+}
+
+func Div(left, right any) Expr {
+	return binary(left, "/", right)
+}
+
+// conditions:
+
+func Eq(left, right any) Expr {
+	return binary(left, "=", right)
+}
+
+func Ne(left, right any) Expr {
+	return binary(left, "<>", right)
+}
+
+func Gt(left, right any) Expr {
+	return binary(left, ">", right)
+}
+
+func Gte(left, right any) Expr {
+	return binary(left, ">=", right)
+}
+
+func Lt(left, right any) Expr {
+	return binary(left, "<", right)
+}
+
+func Lte(left, right any) Expr {
+	return binary(left, "<=", right)
+}
+
+func IsNull(operand any) Expr {
+	return exprFunc(func(b *Builder) {
+		b.Operand(operand)
+		b.Write(" IS NULL")
+	})
+}
+
+func IsNotNull(operand any) Expr {
+	return exprFunc(func(b *Builder) {
+		b.Operand(operand)
+		b.Write(" IS NOT NULL")
+	})
+}
+
+func Not(condition Expr) Expr {
+	return exprFunc(func(b *Builder) {
+		b.Write("NOT ")
+		condition.Build(b)
+	})
+}
+
+func And(conditions ...Expr) Expr {
+	return logical("AND", conditions)
+}
+
+func Or(conditions ...Expr) Expr {
+	return logical("OR", conditions)
+}
+
+func logical(operator string, conditions []Expr) Expr {
+	if len(conditions) == 0 {
+		panic(fmt.Sprintf("Error on %s function: conditions cannot be empty.", operator))
+	}
+
+	return exprFunc(func(b *Builder) {
+		b.Write("(")
+
+		for i, condition := range conditions {
+			if i != 0 {
+				b.Write(fmt.Sprintf(" %s ", operator))
+			}
+
+			condition.Build(b)
+		}
+
+		b.Write(")")
+	})
+}
+
+// CaseExpr is a searched CASE expression, such as Case().When(Gt(Col("age"), 18), "adult").Else("child").
+type CaseExpr struct {
+	whens   []Expr
+	thens   []any
+	elseVal any
+	hasElse bool
+}
+
+func Case() *CaseExpr {
+	return &CaseExpr{}
+}
+
+func (c *CaseExpr) When(condition Expr, then any) *CaseExpr {
+	copied := c.copy()
+	copied.whens = append(copied.whens, condition)
+	copied.thens = append(copied.thens, then)
+
+	return copied
+}
+
+func (c *CaseExpr) Else(value any) *CaseExpr {
+	copied := c.copy()
+	copied.elseVal = value
+	copied.hasElse = true
+
+	return copied
+}
+
+// copy keeps a CASE that is extended in different ways independent.
+func (c *CaseExpr) copy() *CaseExpr {
+	return &CaseExpr{
+		whens:   append([]Expr(nil), c.whens...),
+		thens:   append([]any(nil), c.thens...),
+		elseVal: c.elseVal,
+		hasElse: c.hasElse,
+	}
+}
+
+func (c *CaseExpr) Build(b *Builder) {
+	if len(c.whens) == 0 {
+		panic("Error on Case expression: it should have at least one When.")
+	}
+
+	b.Write("CASE")
+
+	for i, when := range c.whens {
+		b.Write(" WHEN ")
+		when.Build(b)
+		b.Write(" THEN ")
+		b.Operand(c.thens[i])
+	}
+
+	if c.hasElse {
+		b.Write(" ELSE ")
+		b.Operand(c.elseVal)
+	}
+
+	b.Write(" END")
+}
@@ -0,0 +1,95 @@
+package expr
+
+import "testing"
+
+func TestConcat(t *testing.T) {
+	e := Concat(Col("first_name"), " ", Col("last_name"))
+
+	tests := map[Dialect]string{
+		Mysql:              "CONCAT(first_name, ?, last_name)",
+		Postgresql:         "(first_name || ? || last_name)",
+		Sqlite3:            "(first_name || ? || last_name)",
+		MicrosoftSqlServer: "(first_name + ? + last_name)",
+	}
+
+	for dialect, want := range tests {
+		if got, args := Render(e, dialect); got != want || len(args) != 1 || args[0] != " " {
+			t.Errorf("Unexpected concat for dialect %d: %s %v", dialect, got, args)
+		}
+	}
+}
+
+func TestCase(t *testing.T) {
+	base := Case().When(Gt(Col("age"), 65), "senior")
+	e := base.When(Gte(Col("age"), 18), "adult").Else(Lower(Coalesce(Col("kind"), "child")))
+
+	got, args := Render(e, Postgresql)
+
+	if got != "CASE WHEN (age > ?) THEN ? WHEN (age >= ?) THEN ? ELSE LOWER(COALESCE(kind, ?)) END" || len(args) != 5 {
+		t.Fatalf("Unexpected case expression: %s %v", got, args)
+	}
+
+	if got, _ := Render(base, Postgresql); got != "CASE WHEN (age > ?) THEN ? END" {
+		t.Fatalf("Extending a case expression shouldn't change it: %s", got)
+	}
+}
+
+func TestCastAndArithmetic(t *testing.T) {
+	e := Cast(Mul(Add(Col("price"), 5), Col("quantity")), "INTEGER")
+
+	if got, _ := Render(e, Mysql); got != "CAST(((price + ?) * quantity) AS SIGNED)" {
+		t.Fatalf("Unexpected cast for mysql: %s", got)
+	}
+
+	if got, _ := Render(e, Sqlite3); got != "CAST(((price + ?) * quantity) AS INTEGER)" {
+		t.Fatalf("Unexpected cast for sqlite: %s", got)
+	}
+}
+
+func TestDateTrunc(t *testing.T) {
+	tests := map[Dialect]string{
+		Mysql:              "DATE_FORMAT(created_at, '%Y-%m-01 00:00:00')",
+		Postgresql:         "date_trunc('month', created_at)",
+		Sqlite3:            "strftime('%Y-%m-01 00:00:00', created_at)",
+		MicrosoftSqlServer: "DATEADD(month, DATEDIFF(month, 0, created_at), 0)",
+	}
+
+	for dialect, want := range tests {
+		if got, _ := Render(DateTrunc(Month, Col("created_at")), dialect); got != want {
+			t.Errorf("Unexpected month truncation for dialect %d: %s", dialect, got)
+		}
+	}
+
+	if got, _ := Render(DateTrunc(Minute, Col("created_at")), Mysql); got != "DATE_FORMAT(created_at, '%Y-%m-%d %H:%i:00')" {
+		t.Errorf("Unexpected minute truncation for mysql: %s", got)
+	}
+}
+
+func TestDateAddAndExtract(t *testing.T) {
+	week := Interval{Amount: -2, Unit: Week}
+
+	tests := map[Dialect]string{
+		Mysql:              "DATE_ADD(NOW(), INTERVAL -2 week)",
+		Postgresql:         "(NOW() + INTERVAL '-2 week')",
+		Sqlite3:            "datetime(datetime('now'), '-14 days')",
+		MicrosoftSqlServer: "DATEADD(week, -2, GETDATE())",
+	}
+
+	for dialect, want := range tests {
+		if got, _ := Render(DateAdd(Now(), week), dialect); got != want {
+			t.Errorf("Unexpected date add for dialect %d: %s", dialect, got)
+		}
+	}
+
+	if got, _ := Render(DateAdd(Col("paid_at"), Interval{Amount: 1, Unit: Quarter}), MicrosoftSqlServer); got != "DATEADD(month, 3, paid_at)" {
+		t.Errorf("Quarters should be added as months: %s", got)
+	}
+
+	if got, _ := Render(Extract(DayOfWeek, Col("paid_at")), Mysql); got != "(DAYOFWEEK(paid_at) - 1)" {
+		t.Errorf("Unexpected day of week for mysql: %s", got)
+	}
+
+	if got, _ := Render(Extract(Year, Col("paid_at")), Sqlite3); got != "CAST(strftime('%Y', paid_at) AS INTEGER)" {
+		t.Errorf("Unexpected year for sqlite: %s", got)
+	}
+}
@@ -0,0 +1,269 @@
+package expr
+
+import "fmt"
+
+// date and time:
+
+// Unit is a unit of time for DateTrunc, DateAdd and Extract.
+type Unit string
+
+const (
+	Second  Unit = "second"
+	Minute  Unit = "minute"
+	Hour    Unit = "hour"
+	Day     Unit = "day"
+	Week    Unit = "week"
+	Month   Unit = "month"
+	Quarter Unit = "quarter"
+	Year    Unit = "year"
+
+	// DayOfWeek and DayOfYear can only be extracted. The days of the week start from 0 on sunday on every driver.
+	DayOfWeek Unit = "dow"
+	DayOfYear Unit = "doy"
+)
+
+// Interval is an amount of time for DateAdd, such as Interval{Amount: -7, Unit: Day}.
+type Interval struct {
+	Amount int
+	Unit   Unit
+}
+
+// sqliteFormats are the strftime formats that truncate a timestamp, they're also valid for DATE_FORMAT of mysql
+// once "%M" is replaced with "%i".
+var sqliteFormats = map[Unit]string{
+	Second: "%Y-%m-%d %H:%M:%S",
+	Minute: "%Y-%m-%d %H:%M:00",
+	Hour:   "%Y-%m-%d %H:00:00",
+	Day:    "%Y-%m-%d 00:00:00",
+	Month:  "%Y-%m-01 00:00:00",
+	Year:   "%Y-01-01 00:00:00",
+}
+
+// Now is the current timestamp of the database.
+func Now() Expr {
+	return exprFunc(func(b *Builder) {
+		switch b.Dialect {
+		case Sqlite3:
+			b.Write("datetime('now')")
+		case MicrosoftSqlServer:
+			b.Write("GETDATE()")
+		default:
+			b.Write("NOW()")
+		}
+	})
+}
+
+// DateTrunc truncates a timestamp to the start of it's second, minute, hour, day, week, month, quarter or year.
+// Weeks start on monday. It's date_trunc() on postgresql, DATE_FORMAT() on mysql, strftime() on sqlite and
+// DATEADD() with DATEDIFF() on microsoft sql server. The result is a text on mysql and sqlite.
+func DateTrunc(unit Unit, operand any) Expr {
+	if unit == DayOfWeek || unit == DayOfYear || !validUnit(unit) {
+		panic(fmt.Sprintf("Error on DateTrunc function: invalid unit %q.", unit))
+	}
+
+	return exprFunc(func(b *Builder) {
+		switch b.Dialect {
+		case Postgresql:
+			b.Write(fmt.Sprintf("date_trunc('%s', ", unit))
+			b.Operand(operand)
+			b.Write(")")
+		case Mysql:
+			mysqlDateTrunc(b, unit, operand)
+		case Sqlite3:
+			sqliteDateTrunc(b, unit, operand)
+		case MicrosoftSqlServer:
+			mssqlDateTrunc(b, unit, operand)
+		}
+	})
+}
+
+func mysqlDateTrunc(b *Builder, unit Unit, operand any) {
+	switch unit {
+	case Week:
+		b.Write("DATE_FORMAT(DATE_SUB(")
+		b.Operand(operand)
+		b.Write(", INTERVAL WEEKDAY(")
+		b.Operand(operand)
+		b.Write(") DAY), '%Y-%m-%d 00:00:00')")
+	case Quarter:
+		b.Write("CONCAT(YEAR(")
+		b.Operand(operand)
+		b.Write("), '-', LPAD(QUARTER(")
+		b.Operand(operand)
+		b.Write(") * 3 - 2, 2, '0'), '-01 00:00:00')")
+	default:
+		b.Write("DATE_FORMAT(")
+		b.Operand(operand)
+		b.Write(fmt.Sprintf(", '%s')", mysqlFormat(sqliteFormats[unit])))
+	}
+}
+
+func mysqlFormat(format string) string {
+	formatted := []byte(format)
+
+	for i := 1; i < len(formatted); i++ {
+		if formatted[i-1] == '%' && formatted[i] == 'M' {
+			formatted[i] = 'i'
+		}
+	}
+
+	return string(formatted)
+}
+
+func sqliteDateTrunc(b *Builder, unit Unit, operand any) {
+	switch unit {
+	case Week:
+		// "weekday 0" moves to the next sunday unless it's already sunday, the monday before it starts the week:
+		b.Write("strftime('%Y-%m-%d 00:00:00', ")
+		b.Operand(operand)
+		b.Write(", 'weekday 0', '-6 days')")
+	case Quarter:
+		b.Write("printf('%s-%02d-01 00:00:00', strftime('%Y', ")
+		b.Operand(operand)
+		b.Write("), (CAST(strftime('%m', ")
+		b.Operand(operand)
+		b.Write(") AS INTEGER) + 2) / 3 * 3 - 2)")
+	default:
+		b.Write(fmt.Sprintf("strftime('%s', ", sqliteFormats[unit]))
+		b.Operand(operand)
+		b.Write(")")
+	}
+}
+
+func mssqlDateTrunc(b *Builder, unit Unit, operand any) {
+	switch unit {
+	case Week:
+		// DATEDIFF counts the weeks from sunday, a day is taken back so they start from monday like 1900-01-01:
+		b.Write("DATEADD(week, DATEDIFF(week, 0, DATEADD(day, -1, ")
+		b.Operand(operand)
+		b.Write(")), 0)")
+	case Second:
+		// the seconds since 1900 overflow DATEDIFF, so they're counted from the start of the day:
+		b.Write("DATEADD(second, DATEDIFF(second, CAST(CAST(")
+		b.Operand(operand)
+		b.Write(" AS DATE) AS DATETIME), ")
+		b.Operand(operand)
+		b.Write("), CAST(CAST(")
+		b.Operand(operand)
+		b.Write(" AS DATE) AS DATETIME))")
+	default:
+		b.Write(fmt.Sprintf("DATEADD(%s, DATEDIFF(%s, 0, ", unit, unit))
+		b.Operand(operand)
+		b.Write("), 0)")
+	}
+}
+
+// DateAdd adds an interval to a timestamp, the amount can be negative. The result is a text on sqlite.
+func DateAdd(operand any, interval Interval) Expr {
+	if interval.Unit == DayOfWeek || interval.Unit == DayOfYear || !validUnit(interval.Unit) {
+		panic(fmt.Sprintf("Error on DateAdd function: invalid unit %q.", interval.Unit))
+	}
+
+	amount, unit := interval.Amount, interval.Unit
+
+	// not every driver has quarters and sqlite doesn't have weeks either:
+	if unit == Quarter {
+		amount, unit = amount*3, Month
+	}
+
+	return exprFunc(func(b *Builder) {
+		switch b.Dialect {
+		case Postgresql:
+			b.Write("(")
+			b.Operand(operand)
+			b.Write(fmt.Sprintf(" + INTERVAL '%d %s')", amount, unit))
+		case Mysql:
+			b.Write("DATE_ADD(")
+			b.Operand(operand)
+			b.Write(fmt.Sprintf(", INTERVAL %d %s)", amount, unit))
+		case Sqlite3:
+			if unit == Week {
+				amount, unit = amount*7, Day
+			}
+
+			b.Write("datetime(")
+			b.Operand(operand)
+			b.Write(fmt.Sprintf(", '%+d %ss')", amount, unit))
+		case MicrosoftSqlServer:
+			b.Write(fmt.Sprintf("DATEADD(%s, %d, ", unit, amount))
+			b.Operand(operand)
+			b.Write(")")
+		}
+	})
+}
+
+// Extract gives a part of a timestamp as a number. Week is the iso week of the year, it needs sqlite 3.46 or later.
+func Extract(part Unit, operand any) Expr {
+	if !validUnit(part) {
+		panic(fmt.Sprintf("Error on Extract function: invalid part %q.", part))
+	}
+
+	return exprFunc(func(b *Builder) {
+		switch b.Dialect {
+		case Postgresql:
+			b.Write(fmt.Sprintf("EXTRACT(%s FROM ", part))
+			b.Operand(operand)
+			b.Write(")")
+		case Mysql:
+			switch part {
+			case DayOfWeek:
+				b.Write("(DAYOFWEEK(")
+				b.Operand(operand)
+				b.Write(") - 1)")
+			case DayOfYear:
+				function("DAYOFYEAR", operand).Build(b)
+			case Week:
+				b.Write("WEEK(")
+				b.Operand(operand)
+				b.Write(", 3)")
+			default:
+				b.Write(fmt.Sprintf("EXTRACT(%s FROM ", part))
+				b.Operand(operand)
+				b.Write(")")
+			}
+		case Sqlite3:
+			if part == Quarter {
+				b.Write("((CAST(strftime('%m', ")
+				b.Operand(operand)
+				b.Write(") AS INTEGER) + 2) / 3)")
+
+				return
+			}
+
+			formats := map[Unit]string{Second: "%S", Minute: "%M", Hour: "%H", Day: "%d", Week: "%V", Month: "%m", Year: "%Y", DayOfWeek: "%w", DayOfYear: "%j"}
+
+			b.Write(fmt.Sprintf("CAST(strftime('%s', ", formats[part]))
+			b.Operand(operand)
+			b.Write(") AS INTEGER)")
+		case MicrosoftSqlServer:
+			switch part {
+			case DayOfWeek:
+				// DATEPART depends on DATEFIRST, it's moved so sunday is 0:
+				b.Write("((DATEPART(weekday, ")
+				b.Operand(operand)
+				b.Write(") + @@DATEFIRST - 1) % 7)")
+			case DayOfYear:
+				b.Write("DATEPART(dayofyear, ")
+				b.Operand(operand)
+				b.Write(")")
+			case Week:
+				b.Write("DATEPART(iso_week, ")
+				b.Operand(operand)
+				b.Write(")")
+			default:
+				b.Write(fmt.Sprintf("DATEPART(%s, ", part))
+				b.Operand(operand)
+				b.Write(")")
+			}
+		}
+	})
+}
+
+func validUnit(unit Unit) bool {
+	switch unit {
+	case Second, Minute, Hour, Day, Week, Month, Quarter, Year, DayOfWeek, DayOfYear:
+		return true
+	}
+
+	return false
+}
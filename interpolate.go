@@ -0,0 +1,190 @@
+package neormgo
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// interpolation:
+
+// SQL gives the query with it's values written as literals of the driver, for logs, exports and sql consoles. It
+// doesn't change the builder, the query can still be executed after it. The values are bound on execution, so
+// the outcome of SQL shouldn't be executed with values that come from users.
+func (orm *Neorm) SQL() string {
+	if !orm._Pending {
+		return orm.Interpolate(orm.Query, orm._Args...)
+	}
+
+	copied := *orm
+	copied._Statement = orm._Statement.clone()
+	copied.render()
+
+	return orm.Interpolate(copied.Query, copied._Args...)
+}
+
+// Interpolate writes the values into the placeholders of a query that is written for the driver: "$1" on
+// postgresql, "@p1" on microsoft sql server and "?" on the others. The placeholders in the literals and the comments
+// of the query are skipped.
+func (orm *Neorm) Interpolate(query string, args ...interface{}) string {
+	var b strings.Builder
+
+	written, next := 0, 0
+
+	for i := 0; i < len(query); {
+		if end := skipQuoted(query, i, orm._Driver); end != i {
+			i = end
+			continue
+		}
+
+		index, end := -1, i+1
+
+		switch {
+		case orm._Driver == Postgresql && query[i] == '$':
+			end = digitsEnd(query, i+1)
+			index, _ = strconv.Atoi(query[i+1 : end])
+			index--
+		case orm._Driver == MicrosoftSqlServer && strings.HasPrefix(query[i:], "@p") && (i == 0 || query[i-1] != '@'):
+			end = digitsEnd(query, i+2)
+			index, _ = strconv.Atoi(query[i+2 : end])
+			index--
+		case (orm._Driver == Mysql || orm._Driver == Sqlite3) && query[i] == '?':
+			index = next
+			next++
+		}
+
+		if index < 0 || index >= len(args) {
+			i = end
+			continue
+		}
+
+		b.WriteString(query[written:i])
+		b.WriteString(orm.literal(args[index]))
+		written, i = end, end
+	}
+
+	b.WriteString(query[written:])
+
+	return b.String()
+}
+
+func digitsEnd(query string, i int) int {
+	for i < len(query) && query[i] >= '0' && query[i] <= '9' {
+		i++
+	}
+
+	return i
+}
+
+// literal writes a value as an sql literal of the driver.
+func (orm *Neorm) literal(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case driver.Valuer:
+		converted, err := v.Value()
+		if err != nil {
+			return orm.quote(fmt.Sprintf("%v", value))
+		}
+
+		if _, ok := converted.(driver.Valuer); ok {
+			return orm.quote(fmt.Sprintf("%v", converted))
+		}
+
+		return orm.literal(converted)
+	case string:
+		return orm.quote(v)
+	case []byte:
+		switch orm._Driver {
+		case Postgresql:
+			return fmt.Sprintf("'\\x%s'::bytea", hex.EncodeToString(v))
+		case MicrosoftSqlServer:
+			return "0x" + strings.ToUpper(hex.EncodeToString(v))
+		default:
+			return fmt.Sprintf("X'%s'", strings.ToUpper(hex.EncodeToString(v)))
+		}
+	case time.Time:
+		switch orm._Driver {
+		case Mysql:
+			// go-sql-driver/mysql sends the times in utc unless it's configured otherwise:
+			return orm.quote(v.UTC().Format("2006-01-02 15:04:05.999999"))
+		case MicrosoftSqlServer:
+			// datetime doesn't take offsets and more than 3 digits of fractions:
+			return orm.quote(v.Format("2006-01-02T15:04:05.999"))
+		default:
+			return orm.quote(v.Format("2006-01-02 15:04:05.999999-07:00"))
+		}
+	case bool:
+		switch orm._Driver {
+		case Postgresql, Mysql:
+			return strings.ToUpper(strconv.FormatBool(v))
+		default:
+			if v {
+				return "1"
+			}
+
+			return "0"
+		}
+	}
+
+	rv := reflect.ValueOf(value)
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return orm.quote(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+
+		return strconv.FormatFloat(f, 'g', -1, rv.Type().Bits())
+	case reflect.String:
+		return orm.quote(rv.String())
+	case reflect.Bool:
+		return orm.literal(rv.Bool())
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return "NULL"
+		}
+
+		return orm.literal(rv.Elem().Interface())
+	}
+
+	return orm.quote(fmt.Sprintf("%v", value))
+}
+
+// quote writes a string literal: quotes are doubled, backslashes are escaped on mysql and the strings that aren't
+// ascii are written as unicode strings on microsoft sql server.
+func (orm *Neorm) quote(value string) string {
+	if orm._Driver == Mysql {
+		value = strings.ReplaceAll(value, "\\", "\\\\")
+	}
+
+	value = "'" + strings.ReplaceAll(value, "'", "''") + "'"
+
+	if orm._Driver == MicrosoftSqlServer && !isASCII(value) {
+		return "N" + value
+	}
+
+	return value
+}
+
+func isASCII(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
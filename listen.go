@@ -0,0 +1,167 @@
+package neormgo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// postgresql notifications:
+
+// Notification is a notification of a listened channel. When the connection is lost and reestablished, a
+// notification with Reconnected set is sent instead, the notifications in between are lost. Err is the error that
+// the connection was lost with.
+type Notification struct {
+	Channel     string
+	Payload     string
+	PID         int
+	Reconnected bool
+	Err         error
+}
+
+// Listen subscribes to the given postgresql channels with a dedicated connection and returns the notifications
+// on a channel. It waits for the connection until ctx is done and returns the error of the first attempt if it
+// fails. Lost connections are reestablished and the channels are listened again automatically. The returned
+// channel is closed when ctx is done.
+func (orm *Neorm) Listen(ctx context.Context, channels ...string) (<-chan Notification, error) {
+	if orm._Driver != Postgresql {
+		return nil, fmt.Errorf("Listen is only supported on postgresql")
+	}
+
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("channels cannot be empty")
+	}
+
+	if orm._ConnString == "" {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	var mu sync.Mutex
+	var lost error
+
+	connected := make(chan error, 1)
+	var once sync.Once
+
+	events := func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventConnected:
+			once.Do(func() { connected <- nil })
+		case pq.ListenerEventConnectionAttemptFailed:
+			once.Do(func() { connected <- err })
+		case pq.ListenerEventDisconnected:
+			mu.Lock()
+			lost = err
+			mu.Unlock()
+		}
+	}
+
+	listener := pq.NewListener(orm._ConnString, 10*time.Second, time.Minute, events)
+
+	// Listen of pq waits for the connection as long as it takes, so it's bounded by ctx:
+	listened := make(chan error, 1)
+
+	go func() {
+		select {
+		case err := <-connected:
+			if err != nil {
+				listened <- err
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+
+		for _, channel := range channels {
+			if err := listener.Listen(channel); err != nil {
+				listened <- err
+				return
+			}
+		}
+
+		listened <- nil
+	}()
+
+	select {
+	case err := <-listened:
+		if err != nil {
+			listener.Close()
+
+			return nil, err
+		}
+	case <-ctx.Done():
+		listener.Close()
+
+		return nil, ctx.Err()
+	}
+
+	notifications := make(chan Notification, 64)
+
+	go func() {
+		defer close(notifications)
+		defer listener.Close()
+
+		ticker := time.NewTicker(90 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case n, ok := <-listener.Notify:
+				if !ok {
+					return
+				}
+
+				notification := Notification{Reconnected: true}
+
+				// pq sends nil after a reconnection:
+				if n != nil {
+					notification = Notification{Channel: n.Channel, Payload: n.Extra, PID: n.BePid}
+				} else {
+					mu.Lock()
+					notification.Err = lost
+					mu.Unlock()
+				}
+
+				select {
+				case notifications <- notification:
+				case <-ctx.Done():
+					return
+				}
+			case <-ticker.C:
+				// pinging makes a silently dropped connection to be noticed and reestablished:
+				go listener.Ping()
+			}
+		}
+	}()
+
+	return notifications, nil
+}
+
+// Notify sends a notification to the given postgresql channel. If there is an active transaction,
+// the notification is delivered when it's committed.
+func (orm *Neorm) Notify(channel, payload string) error {
+	if orm._Driver != Postgresql {
+		return fmt.Errorf("Notify is only supported on postgresql")
+	}
+
+	ctx := context.Background()
+	query := "SELECT pg_notify($1, $2)"
+
+	if orm.Tx != nil {
+		_, err := orm.Tx.ExecContext(ctx, query, channel, payload)
+
+		return err
+	}
+
+	if orm.Pool == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+
+	_, err := orm.Pool.ExecContext(ctx, query, channel, payload)
+
+	return err
+}
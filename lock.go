@@ -0,0 +1,221 @@
+package neormgo
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// application locks:
+
+// LockTable is the table that keeps the locks on sqlite, it's created on the first lock.
+var LockTable = "neorm_locks"
+
+// AppLock is an acquired application lock. On postgresql, mysql and microsoft sql server it belongs to a dedicated
+// connection, so it's released by the database if the process dies. On sqlite it's a row of LockTable, which stays
+// there until it's unlocked.
+type AppLock struct {
+	Key    string
+	conn   *sql.Conn
+	driver Driver
+}
+
+// Lock waits until the lock of the given key is acquired or ctx is done.
+func (orm *Neorm) Lock(ctx context.Context, key string) (*AppLock, error) {
+	lock, acquired, err := orm.acquireLock(ctx, key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if !acquired {
+		return nil, fmt.Errorf("lock %q cannot be acquired", key)
+	}
+
+	return lock, nil
+}
+
+// TryLock acquires the lock of the given key if nobody else holds it, it doesn't wait.
+func (orm *Neorm) TryLock(ctx context.Context, key string) (*AppLock, bool, error) {
+	return orm.acquireLock(ctx, key, false)
+}
+
+func (orm *Neorm) acquireLock(ctx context.Context, key string, wait bool) (*AppLock, bool, error) {
+	if orm.Pool == nil {
+		return nil, false, fmt.Errorf("database connection not initialized")
+	}
+
+	conn, err := orm.Pool.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	lock := &AppLock{Key: key, conn: conn, driver: orm._Driver}
+
+	acquired, err := lock.acquire(ctx, wait)
+	if err != nil {
+		discardConn(conn)
+
+		return nil, false, err
+	}
+
+	if !acquired {
+		conn.Close()
+
+		return nil, false, nil
+	}
+
+	return lock, true, nil
+}
+
+func (lock *AppLock) acquire(ctx context.Context, wait bool) (bool, error) {
+	switch lock.driver {
+	case Postgresql:
+		if wait {
+			_, err := lock.conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", advisoryLockId(lock.Key))
+
+			return err == nil, err
+		}
+
+		var acquired bool
+		err := lock.conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", advisoryLockId(lock.Key)).Scan(&acquired)
+
+		return acquired, err
+	case Mysql:
+		timeout := 0
+		if wait {
+			timeout = -1
+		}
+
+		var acquired sql.NullInt64
+		if err := lock.conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", mysqlLockName(lock.Key), timeout).Scan(&acquired); err != nil {
+			return false, err
+		}
+
+		return acquired.Valid && acquired.Int64 == 1, nil
+	case MicrosoftSqlServer:
+		timeout := 0
+		if wait {
+			timeout = -1
+		}
+
+		var result int
+		err := lock.conn.QueryRowContext(ctx, "DECLARE @result int; EXEC @result = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', "+
+			"@LockOwner = 'Session', @LockTimeout = @p2; SELECT @result", lock.Key, timeout).Scan(&result)
+		if err != nil {
+			return false, err
+		}
+
+		// 0 and 1 mean acquired, -1 timed out, the others are errors:
+		if result < -1 {
+			return false, fmt.Errorf("sp_getapplock failed with %d", result)
+		}
+
+		return result >= 0, nil
+	case Sqlite3:
+		if _, err := lock.conn.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (lock_key TEXT PRIMARY KEY, acquired_at INTEGER NOT NULL)", LockTable)); err != nil {
+			return false, err
+		}
+
+		for {
+			result, err := lock.conn.ExecContext(ctx, fmt.Sprintf("INSERT OR IGNORE INTO %s (lock_key, acquired_at) VALUES (?, ?)", LockTable), lock.Key, time.Now().UnixMilli())
+			if err != nil {
+				return false, err
+			}
+
+			if ra, err := result.RowsAffected(); err != nil || ra == 1 || !wait {
+				return ra == 1, err
+			}
+
+			select {
+			case <-ctx.Done():
+				return false, ctx.Err()
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	default:
+		return false, fmt.Errorf("locks are not supported on this driver")
+	}
+}
+
+// Unlock releases the lock and gives it's connection back to the pool. If it fails, the connection is closed
+// instead, which releases the lock on the drivers that bind it to the connection.
+func (lock *AppLock) Unlock(ctx context.Context) (err error) {
+	if lock.conn == nil {
+		return fmt.Errorf("lock %q is already released", lock.Key)
+	}
+
+	defer func() {
+		if err != nil {
+			discardConn(lock.conn)
+		} else {
+			lock.conn.Close()
+		}
+
+		lock.conn = nil
+	}()
+
+	switch lock.driver {
+	case Postgresql:
+		var released bool
+		if err := lock.conn.QueryRowContext(ctx, "SELECT pg_advisory_unlock($1)", advisoryLockId(lock.Key)).Scan(&released); err != nil {
+			return err
+		}
+
+		if !released {
+			return fmt.Errorf("lock %q is not held", lock.Key)
+		}
+	case Mysql:
+		var released sql.NullInt64
+		if err := lock.conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", mysqlLockName(lock.Key)).Scan(&released); err != nil {
+			return err
+		}
+
+		if !released.Valid || released.Int64 != 1 {
+			return fmt.Errorf("lock %q is not held", lock.Key)
+		}
+	case MicrosoftSqlServer:
+		if _, err := lock.conn.ExecContext(ctx, "EXEC sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'", lock.Key); err != nil {
+			return err
+		}
+	case Sqlite3:
+		if _, err := lock.conn.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE lock_key = ?", LockTable), lock.Key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// discardConn closes a connection without giving it back to the pool, since it can still hold a lock after an
+// error. database/sql drops the connections that give driver.ErrBadConn.
+func discardConn(conn *sql.Conn) {
+	conn.Raw(func(any) error {
+		return driver.ErrBadConn
+	})
+
+	conn.Close()
+}
+
+// advisoryLockId turns a key into the 64 bit id that postgresql advisory locks take.
+func advisoryLockId(key string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+
+	return int64(hash.Sum64())
+}
+
+// mysqlLockName shortens the keys that are longer than 64 characters, the limit of mysql lock names.
+func mysqlLockName(key string) string {
+	if len(key) <= 64 {
+		return key
+	}
+
+	sum := sha1.Sum([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -3,10 +3,14 @@ package neormgo
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/Necoo33/neormgo/v2/expr"
 
 	_ "github.com/denisenkom/go-mssqldb"
 	_ "github.com/go-sql-driver/mysql"
@@ -29,7 +33,6 @@ const (
 type Neorm struct {
 	Schema                     string
 	Query                      string
-	_Table                     string
 	Pool                       *sql.DB
 	Tx                         *sql.Tx
 	_Type                      string
@@ -44,6 +47,16 @@ type Neorm struct {
 	_LastInsertIdForPostgresql string
 	_ResultAlias               string
 	_Procedure                 string
+	_Statement                 statement
+	_Pending                   bool
+	_ReturnsRows               bool
+	_ConnString                string
+	_KeepRawStrings            bool
+	CacheStore                 CacheStore
+	_CacheTTL                  time.Duration
+	_Columns                   []Column
+	_Duration                  time.Duration
+	_TxTables                  *txTables
 }
 
 // database connectors:
@@ -52,6 +65,8 @@ func (orm *Neorm) Connect(connString string, driver string) (Neorm, error) {
 	var db *sql.DB
 	var err error
 
+	orm._ConnString = connString
+
 	switch strings.ToLower(driver) {
 	case "mysql", "mariadb":
 		db, err = sql.Open("mysql", connString)
@@ -109,6 +124,7 @@ func (orm *Neorm) Begin() error {
 	}
 
 	orm.Tx = tx
+	orm._TxTables = &txTables{}
 
 	return nil
 }
@@ -121,6 +137,7 @@ func (orm *Neorm) Rollback() error {
 	err := orm.Tx.Rollback()
 
 	orm.Tx = nil
+	orm._TxTables = nil
 	return err
 }
 
@@ -131,7 +148,12 @@ func (orm *Neorm) Commit() error {
 
 	err := orm.Tx.Commit()
 
+	if err == nil {
+		orm.invalidateCommitted(orm._TxTables)
+	}
+
 	orm.Tx = nil
+	orm._TxTables = nil
 
 	return err
 }
@@ -161,6 +183,10 @@ func (orm *Neorm) Close() {
 func (orm *Neorm) QueryDrop() error {
 	ctx := context.Background()
 
+	if orm._Pending {
+		orm.render()
+	}
+
 	if orm.Tx != nil {
 		if strings.HasPrefix(orm.Query, "CREATE TABLE") && orm.Schema != "" {
 			useTable := fmt.Sprintf("USE %s;", orm.Schema)
@@ -213,19 +239,77 @@ type Row struct {
 	Columns map[string]interface{}
 }
 
+// Execute runs the query, the errors it gives back are QueryErrors that keep the query with the error of the driver.
 func (orm *Neorm) Execute() error {
+	if orm._Pending {
+		orm.render()
+	}
+
+	query, args, kind := orm.Query, len(orm._Args), orm._Statement.kind
+
+	if err := orm.execute(); err != nil {
+		return newQueryError(err, query, args, orm._Driver, kind)
+	}
+
+	return nil
+}
+
+func (orm *Neorm) execute() error {
 	ctx := context.Background()
 
+	if orm._Statement.lock != "" && orm.Tx == nil {
+		return fmt.Errorf("queries with a row locking clause can only be executed inside a transaction")
+	}
+
+	started := time.Now()
+	defer func() { orm._Duration = time.Since(started) }()
+
 	orm._Rows = nil
+	orm._Columns = nil
 	orm._Result = nil
 	orm._Count = -1
+	orm._LastInsertIdForPostgresql = ""
+
+	useCache := orm._Type == "s" && orm._CacheTTL > 0 && orm.CacheStore != nil && orm.Tx == nil
+	cacheKey := ""
+
+	if useCache {
+		cacheKey = orm.cacheKey()
+
+		if rows, ok := orm.CacheStore.Get(cacheKey); ok {
+			orm._Args = orm._Args[:0]
+			orm._Rows = rows
+
+			return nil
+		}
+	}
 
 	var stmt *sql.Stmt
 	var newConn *sql.Conn
 	var err error
 
+	// ddl isn't prepared, since it can be several statements such as the fts5 table of sqlite with it's triggers
+	// and a prepared statement only runs the first one:
+	if orm._Statement.kind == "ddl" {
+		if orm.Tx != nil {
+			orm._Result, err = orm.Tx.ExecContext(ctx, orm.Query)
+		} else {
+			orm._Result, err = orm.Pool.ExecContext(ctx, orm.Query)
+		}
+
+		return err
+	}
+
+	query := orm.Query
+
+	if orm._Statement.kind == "insert" && len(orm._Statement.returning) == 0 && orm._Driver == MicrosoftSqlServer {
+		// go-mssqldb doesn't support LastInsertId, the documented way is selecting the identity in the same batch.
+		// @@ROWCOUNT is still the count of the insert there. It's only added here, so SQL shows the insert alone:
+		query = fmt.Sprintf("%s; SELECT CONVERT(BIGINT, SCOPE_IDENTITY()) AS id, @@ROWCOUNT AS affected", strings.TrimSuffix(query, ";"))
+	}
+
 	if orm.Tx != nil {
-		stmt, err = orm.Tx.PrepareContext(ctx, orm.Query)
+		stmt, err = orm.Tx.PrepareContext(ctx, query)
 
 		if err != nil {
 			return err
@@ -238,7 +322,7 @@ func (orm *Neorm) Execute() error {
 
 		defer newConn.Close()
 
-		stmt, err = newConn.PrepareContext(ctx, orm.Query)
+		stmt, err = newConn.PrepareContext(ctx, query)
 
 		if err != nil {
 			return err
@@ -252,45 +336,13 @@ func (orm *Neorm) Execute() error {
 		}
 		defer rows.Close()
 
-		columns, err := rows.Columns()
+		results, err := orm.scanRows(rows)
 		if err != nil {
 			return err
 		}
 
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		for i := range columns {
-			valuePtrs[i] = &values[i]
-		}
-
-		var results []map[string]interface{}
-		for rows.Next() {
-			err := rows.Scan(valuePtrs...)
-
-			if err != nil {
-				return err
-			}
-
-			rows := make(map[string]interface{})
-			for i, col := range columns {
-				var v interface{}
-				val := values[i]
-
-				b, ok := val.([]byte)
-				if ok {
-					v = string(b)
-				} else {
-					v = val
-				}
-
-				rows[col] = v
-			}
-
-			results = append(results, rows)
-		}
-
-		if err = rows.Err(); err != nil {
-			return err
+		if useCache {
+			orm.CacheStore.Set(cacheKey, results, orm._CacheTTL, orm.cacheTags())
 		}
 
 		orm._Args = orm._Args[:0]
@@ -352,99 +404,84 @@ func (orm *Neorm) Execute() error {
 
 		defer rows.Close()
 
-		columns, err := rows.Columns()
+		results, err := orm.scanRows(rows)
 		if err != nil {
 			return err
 		}
 
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		for i := range columns {
-			valuePtrs[i] = &values[i]
+		orm._Args = orm._Args[:0]
+		orm._Rows = results
+
+		orm._ResultAlias = ""
+	} else if orm._ReturnsRows {
+		// queries with a RETURNING (or OUTPUT) clause give back full rows, first column of the first row is
+		// kept as the last insert id:
+		rows, err := stmt.Query(orm._Args...)
+		if err != nil {
+			return err
 		}
+		defer rows.Close()
 
-		var results []map[string]interface{}
-		for rows.Next() {
-			err := rows.Scan(valuePtrs...)
+		results, err := orm.scanRows(rows)
+		if err != nil {
+			return err
+		}
 
+		if len(results) > 0 {
+			columns, err := rows.Columns()
 			if err != nil {
 				return err
 			}
 
-			rows := make(map[string]interface{})
-			for i, col := range columns {
-				var v interface{}
-				val := values[i]
-
-				b, ok := val.([]byte)
-				if ok {
-					v = string(b)
-				} else {
-					v = val
-				}
-
-				rows[col] = v
+			orm._LastInsertIdForPostgresql, err = formatLastInsertId(results[0][columns[0]])
+			if err != nil {
+				return err
 			}
-
-			results = append(results, rows)
-		}
-
-		if err = rows.Err(); err != nil {
-			return err
 		}
 
 		orm._Args = orm._Args[:0]
 		orm._Rows = results
-
-		orm._ResultAlias = ""
-
-		defer rows.Close()
-	} else if orm._Type == "i" {
+	} else if orm._Type == "i" && orm.selectsInsertId() {
 		rows, err := stmt.Query(orm._Args...)
 		if err != nil {
 			return err
 		}
 		defer rows.Close()
 
-		if rows.Next() {
-			// Tek satır ve tek sütun beklediğimiz için direkt alıyoruz
-			var id interface{}
-			if err := rows.Scan(&id); err != nil {
-				return err
+		var id interface{}
+		var affected int64
+
+		// the id is in the first row, microsoft sql server selects the affected rows next to it and the rows of
+		// a custom query are the inserted ones:
+		for rows.Next() {
+			if orm._Statement.kind == "insert" {
+				if err := rows.Scan(&id, &affected); err != nil {
+					return err
+				}
+
+				break
 			}
 
-			switch v := id.(type) {
-			case int64:
-				orm._LastInsertIdForPostgresql = strconv.FormatInt(v, 10)
-			case int32:
-				orm._LastInsertIdForPostgresql = strconv.FormatInt(int64(v), 10)
-			case int16:
-				orm._LastInsertIdForPostgresql = strconv.FormatInt(int64(v), 10)
-			case int8:
-				orm._LastInsertIdForPostgresql = strconv.FormatInt(int64(v), 10)
-			case float64:
-				orm._LastInsertIdForPostgresql = strconv.FormatFloat(v, 'f', -1, 64)
-			case float32:
-				orm._LastInsertIdForPostgresql = strconv.FormatFloat(float64(v), 'f', -1, 64)
-			case bool:
-				orm._LastInsertIdForPostgresql = strconv.FormatBool(v)
-			case []byte:
-				orm._LastInsertIdForPostgresql = string(v)
-			case string:
-				orm._LastInsertIdForPostgresql = v
-			case nil:
-				orm._LastInsertIdForPostgresql = ""
-			default:
-				return fmt.Errorf("unexpected type: %T", v)
+			if affected == 0 {
+				if err := rows.Scan(&id); err != nil {
+					return err
+				}
 			}
+
+			affected++
 		}
 
 		if err := rows.Err(); err != nil {
 			return err
 		}
 
-		// Artık gerek yok, args temizle
+		orm._LastInsertIdForPostgresql, err = formatLastInsertId(id)
+		if err != nil {
+			return err
+		}
+
 		orm._Args = orm._Args[:0]
+		orm._Result = affectedRows(affected)
 	} else {
 		result, err := stmt.ExecContext(ctx, orm._Args...)
 
@@ -457,9 +494,206 @@ func (orm *Neorm) Execute() error {
 		orm._Result = result
 	}
 
+	if orm._Type == "i" || orm._Type == "u" {
+		orm.invalidateCache(orm._Statement.table)
+	}
+
+	for _, table := range orm._Statement.invalidates {
+		orm.invalidateCache(table)
+	}
+
 	return nil
 }
 
+// selectsInsertId tells if an insert gives back it's id as a row instead of a result: the inserts of microsoft sql
+// server select it with SCOPE_IDENTITY, the custom inserts can have a RETURNING or OUTPUT clause on postgresql and
+// microsoft sql server.
+func (orm *Neorm) selectsInsertId() bool {
+	switch {
+	case orm._Driver == MicrosoftSqlServer && orm._Statement.kind == "insert":
+		return true
+	case orm._Statement.kind == "raw" && orm._Driver == Postgresql:
+		return strings.Contains(strings.ToUpper(orm.Query), "RETURNING")
+	case orm._Statement.kind == "raw" && orm._Driver == MicrosoftSqlServer:
+		return strings.Contains(strings.ToUpper(orm.Query), "OUTPUT")
+	}
+
+	return false
+}
+
+// affectedRows is the result of the inserts that select their ids, it only knows the number of the inserted rows.
+type affectedRows int64
+
+func (affected affectedRows) LastInsertId() (int64, error) {
+	return 0, fmt.Errorf("the last insert id is selected by the query, use LastInsertId of the builder")
+}
+
+func (affected affectedRows) RowsAffected() (int64, error) {
+	return int64(affected), nil
+}
+
+func (orm *Neorm) scanRows(rows *sql.Rows) ([]map[string]interface{}, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	orm._Columns = make([]Column, len(columnTypes))
+	for i, columnType := range columnTypes {
+		nullable, _ := columnType.Nullable()
+
+		orm._Columns[i] = Column{Name: columnType.Name(), DatabaseType: columnType.DatabaseTypeName(), Nullable: nullable}
+	}
+
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range columns {
+		valuePtrs[i] = &values[i]
+	}
+
+	var results []map[string]interface{}
+	for rows.Next() {
+		err := rows.Scan(valuePtrs...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{})
+		for i, col := range columns {
+			var v interface{}
+			val := values[i]
+
+			b, ok := val.([]byte)
+			if ok {
+				if orm._KeepRawStrings {
+					v = string(b)
+				} else {
+					v = orm.decodeValue(b, columnTypes[i].DatabaseTypeName())
+				}
+			} else {
+				v = val
+			}
+
+			row[col] = v
+		}
+
+		results = append(results, row)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// decodeValue turns json columns into map[string]interface{} or []interface{} and postgresql arrays into go slices
+// by the database type of the column, anything else or anything that can't be decoded stays as string.
+func (orm *Neorm) decodeValue(value []byte, databaseType string) interface{} {
+	databaseType = strings.ToUpper(databaseType)
+
+	if databaseType == "JSON" || databaseType == "JSONB" {
+		var decoded interface{}
+
+		if err := json.Unmarshal(value, &decoded); err == nil {
+			return decoded
+		}
+
+		return string(value)
+	}
+
+	if orm._Driver != Postgresql || !strings.HasPrefix(databaseType, "_") {
+		return string(value)
+	}
+
+	// postgresql reports array types with the "_" prefix, such as "_INT4" or "_TEXT". numeric arrays are decoded
+	// into []string so they don't lose precision. arrays with null elements cannot be decoded into these slices,
+	// they stay as string:
+	switch strings.TrimPrefix(databaseType, "_") {
+	case "INT2", "INT4", "INT8":
+		var decoded pq.Int64Array
+		if err := decoded.Scan(value); err == nil {
+			return []int64(decoded)
+		}
+	case "FLOAT4", "FLOAT8":
+		var decoded pq.Float64Array
+		if err := decoded.Scan(value); err == nil {
+			return []float64(decoded)
+		}
+	case "BOOL":
+		var decoded pq.BoolArray
+		if err := decoded.Scan(value); err == nil {
+			return []bool(decoded)
+		}
+	case "BYTEA":
+		var decoded pq.ByteaArray
+		if err := decoded.Scan(value); err == nil {
+			return [][]byte(decoded)
+		}
+	case "JSON", "JSONB":
+		var decoded pq.StringArray
+		if err := decoded.Scan(value); err == nil {
+			elements := make([]interface{}, len(decoded))
+
+			for i, element := range decoded {
+				if err := json.Unmarshal([]byte(element), &elements[i]); err != nil {
+					return string(value)
+				}
+			}
+
+			return elements
+		}
+	default:
+		var decoded pq.StringArray
+		if err := decoded.Scan(value); err == nil {
+			return []string(decoded)
+		}
+	}
+
+	return string(value)
+}
+
+// KeepRawStrings disables decoding of json and postgresql array columns, they're returned as string
+// just like the other text values.
+func (orm *Neorm) KeepRawStrings(keep bool) Neorm {
+	orm._KeepRawStrings = keep
+
+	return *orm
+}
+
+func formatLastInsertId(id interface{}) (string, error) {
+	switch v := id.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(v), 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []byte:
+		return string(v), nil
+	case string:
+		return v, nil
+	case nil:
+		return "", nil
+	default:
+		return fmt.Sprintf("%v", v), nil
+	}
+}
+
 func (orm *Neorm) Rows() ([]map[string]interface{}, error) {
 	return orm._Rows, nil
 }
@@ -469,20 +703,32 @@ func (orm *Neorm) Length() int64 {
 }
 
 func (orm *Neorm) LastInsertId() (string, error) {
-	if orm._Driver == Postgresql {
+	if orm._Driver == Postgresql || orm._Driver == MicrosoftSqlServer || orm._ReturnsRows {
 		return orm._LastInsertIdForPostgresql, nil
-	} else {
-		lid, err := orm._Result.LastInsertId()
+	}
 
-		if err != nil {
-			return "", err
-		} else {
-			return strconv.FormatInt(lid, 10), nil
-		}
+	if orm._Result == nil {
+		return "", fmt.Errorf("there is no result to get the last insert id from, execute an insert query first")
+	}
+
+	lid, err := orm._Result.LastInsertId()
+
+	if err != nil {
+		return "", err
+	} else {
+		return strconv.FormatInt(lid, 10), nil
 	}
 }
 
 func (orm *Neorm) RowsAffected() (int64, error) {
+	if orm._Result == nil {
+		if orm._ReturnsRows {
+			return int64(len(orm._Rows)), nil
+		}
+
+		return 0, fmt.Errorf("there is no result to get the affected rows from, execute an insert, update or delete query first")
+	}
+
 	ra, err := orm._Result.RowsAffected()
 
 	if err != nil {
@@ -497,35 +743,28 @@ func (orm *Neorm) RowsAffected() (int64, error) {
 func (orm *Neorm) CreateSchema(name string) Neorm {
 	orm.Schema = name
 
-	orm.Query = fmt.Sprintf("CREATE DATABASE %s", name)
+	orm.startDefinition("createSchema", name)
 
 	return *orm
 }
 
 func (orm *Neorm) Use(schema string) Neorm {
-	orm.Query = fmt.Sprintf("USE %s", schema)
+	orm.setRaw(fmt.Sprintf("USE %s", schema))
 
 	return *orm
 }
 
 func (orm *Neorm) CreateTable(name string) Neorm {
-	orm._Table = name
-
-	orm.Query = fmt.Sprintf("CREATE TABLE %s", name)
+	orm.startDefinition("createTable", name)
 
 	return *orm
 }
 
 func (orm Neorm) IfNotExist() Neorm {
-	if strings.HasPrefix(orm.Query, "CREATE DATABASE") {
-		splitTheQuery := strings.Split(orm.Query, " DATABASE ")
-
-		orm.Query = fmt.Sprintf("%s DATABASE IF NOT EXISTS %s", splitTheQuery[0], splitTheQuery[1])
-	} else if strings.HasPrefix(orm.Query, "CREATE TABLE") {
-		splitTheQuery := strings.Split(orm.Query, " TABLE ")
-
-		orm.Query = fmt.Sprintf("%s TABLE IF NOT EXISTS %s", splitTheQuery[0], splitTheQuery[1])
-	} else {
+	switch orm._Statement.kind {
+	case "createSchema", "createTable":
+		orm.statement().ifNotExists = true
+	default:
 		panic("You cannot add 'IF NOT EXISTS' parameter if you don't start to create a schema or table.")
 	}
 
@@ -533,180 +772,153 @@ func (orm Neorm) IfNotExist() Neorm {
 }
 
 func (orm *Neorm) AddColumn(name string) Neorm {
-	if strings.HasPrefix(orm.Query, "ALTER TABLE") {
-		orm.Query = fmt.Sprintf("%s ADD COLUMN %s", orm.Query, name)
-	} else {
-		splitTheQuery := strings.Split(orm.Query, " ")
-		lengthOfTheQuery := len(splitTheQuery)
-
-		failString := fmt.Sprintf("%s (", orm._Table)
-
-		if (splitTheQuery[lengthOfTheQuery-1] == orm._Table) && !strings.Contains(orm.Query, failString) {
-			orm.Query = fmt.Sprintf("%s (%s", orm.Query, name)
-		} else {
-			orm.Query = fmt.Sprintf("%s, %s", orm.Query, name)
-		}
+	switch orm._Statement.kind {
+	case "alterTable":
+		orm.addDefinition(definition{text: fmt.Sprintf("ADD COLUMN %s", name), column: true})
+	case "createTable":
+		orm.addDefinition(definition{text: name, column: true})
+	default:
+		panic("You cannot add a column if you don't start to create or alter a table.")
 	}
 
 	return *orm
 }
 
 func (orm *Neorm) Type(typeVal string) Neorm {
-	orm.Query = fmt.Sprintf("%s %s", orm.Query, strings.ToUpper(typeVal))
+	d := orm.lastDefinition()
+
+	if d.dataType == "" && len(d.parts) == 0 {
+		d.dataType = strings.ToUpper(typeVal)
+	} else {
+		d.parts = append(d.parts, strings.ToUpper(typeVal))
+	}
 
 	return *orm
 }
 
 func (orm *Neorm) Null() Neorm {
-	orm.Query = fmt.Sprintf("%s NULL", orm.Query)
+	orm.modify("NULL")
 
 	return *orm
 }
 
 func (orm *Neorm) NotNull() Neorm {
-	orm.Query = fmt.Sprintf("%s NOT NULL", orm.Query)
+	orm.modify("NOT NULL")
 
 	return *orm
 }
 
 func (orm *Neorm) AutoIncrement() Neorm {
-	orm.Query = fmt.Sprintf("%s AUTO_INCREMENT", orm.Query)
+	orm.modify("AUTO_INCREMENT")
 
 	return *orm
 }
 
 func (orm *Neorm) Default(value interface{}) Neorm {
-	splitTheQuery := strings.Split(orm.Query, ", ")
-
-	lengthOfTheSplitTheQuery := len(splitTheQuery)
-
-	splitTheSplittedQuery := strings.Split(splitTheQuery[lengthOfTheSplitTheQuery-1], " ")
+	columnType := strings.Split(orm.lastDefinition().dataType, "(")[0]
 
-	if splitTheSplittedQuery[1] == "INT" ||
-		splitTheSplittedQuery[1] == "TINYINT" ||
-		splitTheSplittedQuery[1] == "SMALLINT" ||
-		splitTheSplittedQuery[1] == "MEDIUMINT" ||
-		splitTheSplittedQuery[1] == "BIGINT" ||
-		splitTheSplittedQuery[1] == "BIT" {
+	switch columnType {
+	case "INT", "TINYINT", "SMALLINT", "MEDIUMINT", "BIGINT", "BIT":
 		switch t := value.(type) {
 		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
-			orm.Query = fmt.Sprintf("%s DEFAULT %d", orm.Query, t)
+			orm.modify(fmt.Sprintf("DEFAULT %d", t))
 		default:
 			panic("You cannot give a non integer go value to a column if it's a mysql integer variant.")
 		}
-	}
-
-	if splitTheSplittedQuery[1] == "BOOL" ||
-		splitTheSplittedQuery[1] == "BOOLEAN" {
+	case "BOOL", "BOOLEAN":
 		switch t := value.(type) {
 		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
 			if t != 0 && t != 1 {
 				panic("You cannot give a default value none other than 1 or 0 if a mysql column has the Boolean type")
 			} else {
-				orm.Query = fmt.Sprintf("%s DEFAULT %d", orm.Query, t)
+				orm.modify(fmt.Sprintf("DEFAULT %d", t))
 			}
 		case bool:
-			orm.Query = fmt.Sprintf("%s DEFAULT %v", orm.Query, t)
+			orm.modify(fmt.Sprintf("DEFAULT %v", t))
 		default:
 			panic("You cannot give any other default value to a boolean column none other than an integer type or boolean")
 		}
-	}
-
-	splitTheColumnType := strings.Split(splitTheSplittedQuery[1], "(")[0]
-
-	if splitTheColumnType == "CHAR" ||
-		splitTheColumnType == "VARCHAR" ||
-		splitTheColumnType == "TEXT" ||
-		splitTheColumnType == "TINYTEXT" ||
-		splitTheColumnType == "MEDIUMTEXT" ||
-		splitTheColumnType == "LONGTEXT" ||
-		splitTheColumnType == "BINARY" ||
-		splitTheColumnType == "VARBINARY" {
+	case "CHAR", "VARCHAR", "TEXT", "TINYTEXT", "MEDIUMTEXT", "LONGTEXT", "BINARY", "VARBINARY":
 		switch t := value.(type) {
 		case string, map[string]interface{}:
-			orm.Query = fmt.Sprintf("%s DEFAULT '%s'", orm.Query, t)
+			orm.modify(fmt.Sprintf("DEFAULT '%s'", t))
 		default:
 			panic("You cannot give any other default value than string or json if your column is a mysql string variant.")
 		}
-	}
-
-	if splitTheColumnType == "DATETIME" ||
-		splitTheColumnType == "TIMESTAMP" {
+	case "DATETIME", "TIMESTAMP":
 		switch t := value.(type) {
 		case string, map[string]interface{}:
-			orm.Query = fmt.Sprintf("%s DEFAULT %s", orm.Query, t)
+			orm.modify(fmt.Sprintf("DEFAULT %s", t))
 		default:
 			panic("You cannot give any other default value than string or json if your column is a mysql string variant.")
 		}
+	default:
+		switch t := value.(type) {
+		case string:
+			orm.modify(fmt.Sprintf("DEFAULT '%s'", strings.ReplaceAll(t, "'", "''")))
+		default:
+			orm.modify(fmt.Sprintf("DEFAULT %v", t))
+		}
 	}
 
 	return *orm
 }
 
 func (orm *Neorm) Unique() Neorm {
-	orm.Query = fmt.Sprintf("%s UNIQUE", orm.Query)
+	orm.modify("UNIQUE")
 
 	return *orm
 }
 
 func (orm *Neorm) Check(condition string) Neorm {
-	orm.Query = fmt.Sprintf("%s CHECK (%s)", orm.Query, condition)
+	orm.modify(fmt.Sprintf("CHECK (%s)", condition))
 
 	return *orm
 }
 
 func (orm *Neorm) CharacterSet(characterSet string) Neorm {
-	orm.Query = fmt.Sprintf("%s CHARACTER SET %s", orm.Query, characterSet)
+	orm.modify(fmt.Sprintf("CHARACTER SET %s", characterSet))
 
 	return *orm
 }
 
 func (orm *Neorm) PrimaryKey() Neorm {
-	if strings.Contains(orm.Query, "PRIMARY KEY") {
-		panic("A table cannot has two primary key")
+	for _, d := range orm._Statement.definitions {
+		if strings.Contains(d.render(), "PRIMARY KEY") {
+			panic("A table cannot has two primary key")
+		}
 	}
 
-	orm.Query = fmt.Sprintf("%s PRIMARY KEY", orm.Query)
+	orm.modify("PRIMARY KEY")
 
 	return *orm
 }
 
 func (orm *Neorm) ForeignKey(column string, referenceStruct interface{}) Neorm {
-	if strings.HasPrefix(orm.Query, "ALTER TABLE") {
-		orm.Query = fmt.Sprintf("%s ADD FOREIGN KEY (%s)", orm.Query, column)
-	} else {
-		orm.Query = fmt.Sprintf("%s, FOREIGN KEY (%s)", orm.Query, column)
-	}
-
-	referencesValues := reflect.ValueOf(referenceStruct)
-	if referencesValues.Kind() != reflect.Struct {
-		panic("The references of foreign keys must be a struct.")
-	}
-
-	referencesFields := referencesValues.Type()
-
-	for i := 0; i < referencesValues.NumField(); i++ {
-		fieldValue := referencesValues.Field(i).Interface()
-		fieldName := referencesFields.Field(i).Name
+	references := foreignKeyReferences(referenceStruct, true)
 
-		switch t := fieldValue.(type) {
-		case string:
-			orm.Query = fmt.Sprintf("%s REFERENCES %s(%s)", orm.Query, strings.ToLower(fieldName), t)
-		default:
-			panic("Any values of referencesStruct argument cannot be other than string.")
-		}
+	if orm._Statement.kind == "alterTable" {
+		orm.addDefinition(definition{text: fmt.Sprintf("ADD FOREIGN KEY (%s)", column), parts: references})
+	} else {
+		orm.addDefinition(definition{text: fmt.Sprintf("FOREIGN KEY (%s)", column), parts: references})
 	}
 
 	return *orm
 }
 
 func (orm *Neorm) ForeignKeyWithConstraint(constraint, column string, referenceStruct interface{}) Neorm {
-	if strings.HasPrefix(orm.Query, "ALTER TABLE") {
-		orm.Query = fmt.Sprintf("%s ADD CONSTRAINT %s FOREIGN KEY (%s)", orm.Query, constraint, column)
+	references := foreignKeyReferences(referenceStruct, false)
+
+	if orm._Statement.kind == "alterTable" {
+		orm.addDefinition(definition{text: fmt.Sprintf("ADD CONSTRAINT %s FOREIGN KEY (%s)", constraint, column), parts: references})
 	} else {
-		orm.Query = fmt.Sprintf("%s, CONSTRAINT %s FOREIGN KEY (%s)", orm.Query, constraint, column)
+		orm.addDefinition(definition{text: fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s)", constraint, column), parts: references})
 	}
 
+	return *orm
+}
+
+func foreignKeyReferences(referenceStruct interface{}, lowerTableNames bool) []string {
 	referencesValues := reflect.ValueOf(referenceStruct)
 	if referencesValues.Kind() != reflect.Struct {
 		panic("The references of foreign keys must be a struct.")
@@ -714,87 +926,89 @@ func (orm *Neorm) ForeignKeyWithConstraint(constraint, column string, referenceS
 
 	referencesFields := referencesValues.Type()
 
+	var references []string
+
 	for i := 0; i < referencesValues.NumField(); i++ {
 		fieldValue := referencesValues.Field(i).Interface()
 		fieldName := referencesFields.Field(i).Name
 
+		if lowerTableNames {
+			fieldName = strings.ToLower(fieldName)
+		}
+
 		switch t := fieldValue.(type) {
 		case string:
-			orm.Query = fmt.Sprintf("%s REFERENCES %s(%s)", orm.Query, fieldName, t)
+			references = append(references, fmt.Sprintf("REFERENCES %s(%s)", fieldName, t))
 		default:
 			panic("Any values of referencesStruct argument cannot be other than string.")
 		}
 	}
 
-	return *orm
+	return references
 }
 
 func (orm *Neorm) Unsigned() Neorm {
-	orm.Query = fmt.Sprintf("%s UNSIGNED", orm.Query)
+	orm.modify("UNSIGNED")
 
 	return *orm
 }
 
 func (orm *Neorm) Zerofill() Neorm {
-	orm.Query = fmt.Sprintf("%s ZEROFILL", orm.Query)
+	orm.modify("ZEROFILL")
 
 	return *orm
 }
 
 func (orm *Neorm) Enum(values []string) Neorm {
-	orm.Query = fmt.Sprintf("%s ENUM(", orm.Query)
-
-	lengthOfValues := len(values)
+	quoted := make([]string, len(values))
 
 	for i, value := range values {
-		if i+1 == lengthOfValues {
-			orm.Query = fmt.Sprintf("%s'%s')", orm.Query, value)
-		} else {
-			orm.Query = fmt.Sprintf("%s'%s', ", orm.Query, value)
-		}
+		quoted[i] = fmt.Sprintf("'%s'", value)
 	}
 
+	orm.modify(fmt.Sprintf("ENUM(%s)", strings.Join(quoted, ", ")))
+
 	return *orm
 }
 
 func (orm *Neorm) OnUpdate(newValue string) Neorm {
-	orm.Query = fmt.Sprintf("%s ON UPDATE %s", orm.Query, newValue)
+	orm.modify(fmt.Sprintf("ON UPDATE %s", newValue))
 
 	return *orm
 }
 
 func (orm *Neorm) OnDelete(newValue string) Neorm {
-	orm.Query = fmt.Sprintf("%s ON DELETE %s", orm.Query, newValue)
+	orm.modify(fmt.Sprintf("ON DELETE %s", newValue))
 
 	return *orm
 }
 
 func (orm *Neorm) GeneratedAlways(condition string) Neorm {
-	orm.Query = fmt.Sprintf("%s GENERATED ALWAYS AS %s", orm.Query, condition)
+	orm.modify(fmt.Sprintf("GENERATED ALWAYS AS %s", condition))
 
 	return *orm
 }
 
 func (orm *Neorm) Virtual() Neorm {
-	orm.Query = fmt.Sprintf("%s VIRTUAL", orm.Query)
+	orm.modify("VIRTUAL")
 
 	return *orm
 }
 
 func (orm *Neorm) Stored() Neorm {
-	orm.Query = fmt.Sprintf("%s STORED", orm.Query)
+	orm.modify("STORED")
 
 	return *orm
 }
 
 func (orm *Neorm) Spatial() Neorm {
-	orm.Query = fmt.Sprintf("%s SPATIAL", orm.Query)
+	orm.modify("SPATIAL")
 
 	return *orm
 }
 
 func (orm *Neorm) Generated() Neorm {
-	orm.Query = fmt.Sprintf("%s GENERATED", orm.Query)
+	orm.modify("GENERATED")
 
 	return *orm
 }
@@ -802,32 +1016,31 @@ func (orm *Neorm) Generated() Neorm {
 func (orm *Neorm) Index(index interface{}) Neorm {
 	switch t := index.(type) {
 	case string:
-		orm.Query = fmt.Sprintf("%s, INDEX (%s)", orm.Query, t)
+		orm.addDefinition(definition{text: fmt.Sprintf("INDEX (%s)", t)})
 	case []string:
-		lengthOfT := len(t)
-
-		for i, value := range t {
-			if i+1 == lengthOfT {
-				orm.Query = fmt.Sprintf("%s%s)", orm.Query, value)
-
-				continue
-			}
+		orm.addDefinition(definition{text: fmt.Sprintf("INDEX (%s)", strings.Join(t, ", "))})
+	}
 
-			if i == 0 {
-				orm.Query = fmt.Sprintf("%s, INDEX (%s, ", orm.Query, value)
+	return *orm
+}
 
-				continue
-			}
+// FulltextIndex adds a fulltext index to a mysql table while creating it, use CreateFulltextIndex for the other drivers.
+func (orm *Neorm) FulltextIndex(columns ...string) Neorm {
+	if orm._Driver != Mysql {
+		panic("FulltextIndex is only supported on mysql while creating a table, use CreateFulltextIndex instead.")
+	}
 
-			orm.Query = fmt.Sprintf("%s%s, ", orm.Query, value)
-		}
+	if len(columns) == 0 {
+		panic("Error on FulltextIndex method: columns cannot be empty.")
 	}
 
+	orm.addDefinition(definition{text: fmt.Sprintf("FULLTEXT (%s)", strings.Join(columns, ", "))})
+
 	return *orm
 }
 
 func (orm *Neorm) Comment(comment string) Neorm {
-	orm.Query = fmt.Sprintf("%s COMMENT '%s'", orm.Query, comment)
+	orm.modify(fmt.Sprintf("COMMENT '%s'", comment))
 
 	return *orm
 }
@@ -835,26 +1048,26 @@ func (orm *Neorm) Comment(comment string) Neorm {
 func (orm *Neorm) DefaultOnNull(value interface{}) Neorm {
 	switch t := value.(type) {
 	case string:
-		orm.Query = fmt.Sprintf("%s DEFAULT '%s' ON NULL", orm.Query, t)
+		orm.modify(fmt.Sprintf("DEFAULT '%s' ON NULL", t))
 	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
-		orm.Query = fmt.Sprintf("%s DEFAULT %d ON NULL", orm.Query, t)
+		orm.modify(fmt.Sprintf("DEFAULT %d ON NULL", t))
 	case float32, float64:
-		orm.Query = fmt.Sprintf("%s DEFAULT %f ON NULL", orm.Query, t)
+		orm.modify(fmt.Sprintf("DEFAULT %f ON NULL", t))
 	case bool:
-		orm.Query = fmt.Sprintf("%s DEFAULT %v ON NULL", orm.Query, t)
+		orm.modify(fmt.Sprintf("DEFAULT %v ON NULL", t))
 	}
 
 	return *orm
 }
 
 func (orm *Neorm) Invisible() Neorm {
-	orm.Query = fmt.Sprintf("%s INVISIBLE", orm.Query)
+	orm.modify("INVISIBLE")
 
 	return *orm
 }
 
 func (orm *Neorm) CustomKeyword(keywordAndValue string) Neorm {
-	orm.Query = fmt.Sprintf("%s %s", orm.Query, keywordAndValue)
+	orm.modify(keywordAndValue)
 
 	return *orm
 }
@@ -862,147 +1075,218 @@ func (orm *Neorm) CustomKeyword(keywordAndValue string) Neorm {
 // altering functions for columns:
 
 func (orm *Neorm) AlterTable(name string) Neorm {
-	orm.Query = fmt.Sprintf("ALTER TABLE %s", name)
+	orm.startDefinition("alterTable", name)
 
 	return *orm
 }
 
 func (orm *Neorm) Add(something string) Neorm {
-	orm.Query = fmt.Sprintf("%s ADD %s", orm.Query, something)
+	orm.addAction(fmt.Sprintf("ADD %s", something))
 
 	return *orm
 }
 
 func (orm *Neorm) Drop(something string) Neorm {
-	orm.Query = fmt.Sprintf("%s DROP %s", orm.Query, something)
+	orm.addAction(fmt.Sprintf("DROP %s", something))
 
 	return *orm
 }
 
 func (orm *Neorm) ModifyColumn(column string) Neorm {
-	orm.Query = fmt.Sprintf("%s MODIFY COLUMN %s", orm.Query, column)
+	orm.addAction(fmt.Sprintf("MODIFY COLUMN %s", column))
 
 	return *orm
 }
 
 func (orm *Neorm) ChangeColumn(oldColumn, newColumn string) Neorm {
-	orm.Query = fmt.Sprintf("%s CHANGE COLUMN %s %s", orm.Query, oldColumn, newColumn)
+	orm.addAction(fmt.Sprintf("CHANGE COLUMN %s %s", oldColumn, newColumn))
 
 	return *orm
 }
 
 func (orm *Neorm) After(columnFromAfter string) Neorm {
-	orm.Query = fmt.Sprintf("%s AFTER %s", orm.Query, columnFromAfter)
+	orm.modify(fmt.Sprintf("AFTER %s", columnFromAfter))
 
 	return *orm
 }
 
 func (orm *Neorm) First() Neorm {
-	orm.Query = fmt.Sprintf("%s FIRST", orm.Query)
+	orm.modify("FIRST")
 
 	return *orm
 }
 
 func (orm *Neorm) DropColumn(column string) Neorm {
-	orm.Query = fmt.Sprintf("%s DROP COLUMN %s", orm.Query, column)
+	orm.addAction(fmt.Sprintf("DROP COLUMN %s", column))
 
 	return *orm
 }
 
 func (orm *Neorm) AddIndex(indexName, column string) Neorm {
-	orm.Query = fmt.Sprintf("%s ADD INDEX %s (%s)", orm.Query, indexName, column)
+	orm.addAction(fmt.Sprintf("ADD INDEX %s (%s)", indexName, column))
 
 	return *orm
 }
 
 func (orm *Neorm) AddUniqueIndex(indexName, column string) Neorm {
-	orm.Query = fmt.Sprintf("%s ADD UNIQUE INDEX %s (%s)", orm.Query, indexName, column)
+	orm.addAction(fmt.Sprintf("ADD UNIQUE INDEX %s (%s)", indexName, column))
 
 	return *orm
 }
 
 func (orm *Neorm) DropIndex(index string) Neorm {
-	orm.Query = fmt.Sprintf("%s DROP INDEX %s", orm.Query, index)
+	orm.addAction(fmt.Sprintf("DROP INDEX %s", index))
 
 	return *orm
 }
 
 func (orm *Neorm) AddPrimaryKey(column string) Neorm {
-	orm.Query = fmt.Sprintf("%s ADD PRIMARY KEY (%s)", orm.Query, column)
+	orm.addAction(fmt.Sprintf("ADD PRIMARY KEY (%s)", column))
 
 	return *orm
 }
 
 func (orm *Neorm) DropPrimaryKey() Neorm {
-	orm.Query = fmt.Sprintf("%s DROP PRIMARY KEY", orm.Query)
+	orm.addAction("DROP PRIMARY KEY")
 
 	return *orm
 }
 
 func (orm *Neorm) DropForeingKey(foreignKey string) Neorm {
-	orm.Query = fmt.Sprintf("%s DROP FOREIGN KEY %s", orm.Query, foreignKey)
+	orm.addAction(fmt.Sprintf("DROP FOREIGN KEY %s", foreignKey))
 
 	return *orm
 }
 
 func (orm *Neorm) RenameColumn(oldName, newName string) Neorm {
-	orm.Query = fmt.Sprintf("%s RENAME COLUMN %s TO %s", orm.Query, oldName, newName)
+	orm.addAction(fmt.Sprintf("RENAME COLUMN %s TO %s", oldName, newName))
 
 	return *orm
 }
 
 func (orm *Neorm) RenameTable(newName string) Neorm {
-	orm.Query = fmt.Sprintf("%s RENAME TO %s", orm.Query, newName)
+	orm.addAction(fmt.Sprintf("RENAME TO %s", newName))
 
 	return *orm
 }
 
 func (orm *Neorm) AddConstraint(constraint string) Neorm {
-	orm.Query = fmt.Sprintf("%s ADD CONSTRAINT %s", orm.Query, constraint)
+	orm.addAction(fmt.Sprintf("ADD CONSTRAINT %s", constraint))
 
 	return *orm
 }
 
 func (orm *Neorm) DropConstraint(constraint string) Neorm {
-	orm.Query = fmt.Sprintf("%s DROP CONSTRAINT %s", orm.Query, constraint)
+	orm.addAction(fmt.Sprintf("DROP CONSTRAINT %s", constraint))
 
 	return *orm
 }
 
 func (orm *Neorm) AddFulltextIndex(column string) Neorm {
-	orm.Query = fmt.Sprintf("%s ADD FULLTEXT (%s)", orm.Query, column)
+	orm.addAction(fmt.Sprintf("ADD FULLTEXT (%s)", column))
 
 	return *orm
 }
 
 func (orm *Neorm) AddSpatialIndex(column string) Neorm {
-	orm.Query = fmt.Sprintf("%s ADD SPATIAL INDEX (%s)", orm.Query, column)
+	orm.addAction(fmt.Sprintf("ADD SPATIAL INDEX (%s)", column))
 
 	return *orm
 }
 
-func (orm *Neorm) DisableKeys() Neorm {
-	orm.Query = fmt.Sprintf("%s DISABLE KEYS", orm.Query)
+// CreateFulltextIndex creates the index that Match uses:
+//
+// mysql: a FULLTEXT index named name.
+// postgresql: a GIN index named name on the same tsvector expression that Match renders.
+// sqlite: a fts5 virtual table named name that takes it's content from table, Match should be used on that table.
+// The existing rows are indexed and triggers named after it keep it in sync with the inserts, updates and deletes.
+// microsoft sql server: a full-text index on the default catalog, name should be the unique key index of the table.
+func (orm *Neorm) CreateFulltextIndex(name, table string, columns []string) Neorm {
+	if len(columns) == 0 {
+		panic("Error on CreateFulltextIndex method: columns cannot be empty.")
+	}
+
+	switch orm._Driver {
+	case Postgresql:
+		orm.setRaw(fmt.Sprintf("CREATE INDEX %s ON %s USING GIN (%s)", name, table, textSearchDocument(columns)))
+	case Sqlite3:
+		orm.setRaw(sqliteFulltextIndex(name, table, columns))
+	case MicrosoftSqlServer:
+		orm.setRaw(fmt.Sprintf("CREATE FULLTEXT INDEX ON %s (%s) KEY INDEX %s", table, strings.Join(columns, ", "), name))
+	default:
+		orm.setRaw(fmt.Sprintf("CREATE FULLTEXT INDEX %s ON %s (%s)", name, table, strings.Join(columns, ", ")))
+	}
+
+	return *orm
+}
+
+func (orm *Neorm) DisableKeys() Neorm {
+	orm.addAction("DISABLE KEYS")
 
 	return *orm
 }
 
 func (orm *Neorm) EnableKeys() Neorm {
-	orm.Query = fmt.Sprintf("%s ENABLE KEYS", orm.Query)
+	orm.addAction("ENABLE KEYS")
 
 	return *orm
 }
 
 func (orm *Neorm) Engine(engine string) Neorm {
-	orm.Query = fmt.Sprintf("%s ENGINE = %s", orm.Query, engine)
+	if orm._Statement.kind == "createTable" {
+		st := orm.statement()
+		st.options = append(st.options, fmt.Sprintf("ENGINE = %s", engine))
+	} else {
+		orm.addAction(fmt.Sprintf("ENGINE = %s", engine))
+	}
 
 	return *orm
 }
 
+func (orm *Neorm) startDefinition(kind, name string) {
+	orm._Statement = statement{kind: kind, table: name}
+	orm._Pending = true
+	orm.Query = ""
+}
+
+func (orm *Neorm) addDefinition(d definition) {
+	st := orm.statement()
+
+	if st.kind != "createTable" && st.kind != "alterTable" {
+		panic("You should start to create or alter a table first.")
+	}
+
+	st.definitions = append(st.definitions, d)
+}
+
+func (orm *Neorm) addAction(action string) {
+	if orm._Statement.kind != "alterTable" {
+		panic(fmt.Sprintf("'%s' can only be used while altering a table.", action))
+	}
+
+	orm.addDefinition(definition{text: action})
+}
+
+func (orm *Neorm) lastDefinition() *definition {
+	st := orm.statement()
+
+	if len(st.definitions) == 0 {
+		panic("You should add a column first.")
+	}
+
+	return &st.definitions[len(st.definitions)-1]
+}
+
+// modify appends a keyword to the last column, constraint or action.
+func (orm *Neorm) modify(part string) {
+	d := orm.lastDefinition()
+	d.parts = append(d.parts, part)
+}
+
 // user actions:
 
 func (orm *Neorm) CreateUser(name, scope string) Neorm {
-	orm.Query = "CREATE USER"
+	orm.setRaw("CREATE USER")
 
 	return *orm
 }
@@ -1011,7 +1295,7 @@ func (orm *Neorm) UserInfos(username, scope, password string) Neorm {
 	orm._User = username
 	orm._Password = password
 	orm._Scope = scope
-	orm.Query = fmt.Sprintf("%s '%s'@'%s' IDENTIFIED BY %s", orm.Query, username, scope, password)
+	orm.setRaw(fmt.Sprintf("%s '%s'@'%s' IDENTIFIED BY %s", orm.Query, username, scope, password))
 
 	return *orm
 }
@@ -1088,7 +1372,7 @@ func (orm *Neorm) GrantPrivileges(privileges interface{}, schema string) Neorm {
 		panic("privileges has to be either string or string array")
 	}
 
-	orm.Query = fmt.Sprintf("%s ON %s TO '%s'@'%s' ", orm.Query, schema, orm._User, orm._Scope)
+	orm.setRaw(fmt.Sprintf("%s ON %s TO '%s'@'%s' ", orm.Query, schema, orm._User, orm._Scope))
 
 	return *orm
 }
@@ -1165,73 +1449,134 @@ func (orm *Neorm) RevokePrivileges(privileges interface{}, schema string) Neorm
 		panic("privileges has to be either string or string array")
 	}
 
-	orm.Query = fmt.Sprintf("%s ON %s TO '%s'@'%s' ", orm.Query, schema, orm._User, orm._Scope)
+	orm.setRaw(fmt.Sprintf("%s ON %s TO '%s'@'%s' ", orm.Query, schema, orm._User, orm._Scope))
 
 	return *orm
 }
 
 func (orm *Neorm) ShowGrants() Neorm {
-	orm.Query = fmt.Sprintf("SHOW GRANTS FOR '%s'@'%s'", orm._User, orm._Scope)
+	orm.setRaw(fmt.Sprintf("SHOW GRANTS FOR '%s'@'%s'", orm._User, orm._Scope))
 
 	return *orm
 }
 
 func (orm *Neorm) SetPassword(password string) Neorm {
-	orm.Query = fmt.Sprintf("SET PASSWORD FOR '%s'@'%s' = PASSWORD(%s)", orm._User, orm._Scope, password)
+	orm.setRaw(fmt.Sprintf("SET PASSWORD FOR '%s'@'%s' = PASSWORD(%s)", orm._User, orm._Scope, password))
 
 	return *orm
 }
 
 func (orm *Neorm) DropUser(user, scope string) Neorm {
-	orm.Query = fmt.Sprintf("DROP USER '%s'@'%s'", user, scope)
+	orm.setRaw(fmt.Sprintf("DROP USER '%s'@'%s'", user, scope))
 
 	return *orm
 }
 
 func (orm *Neorm) AllUsers() Neorm {
-	orm.Query = "SELECT user, host FROM mysql.user"
+	orm.setRaw("SELECT user, host FROM mysql.user")
 
 	return *orm
 }
 
 func (orm *Neorm) RenameUser(newName, newScope string) Neorm {
-	orm.Query = fmt.Sprintf("RENAME USER '%s'@'%s' TO '%s'@'%s'", orm._User, orm._Scope, newName, newScope)
+	orm.setRaw(fmt.Sprintf("RENAME USER '%s'@'%s' TO '%s'@'%s'", orm._User, orm._Scope, newName, newScope))
 
 	return *orm
 }
 
 func (orm *Neorm) SetDefaultRole(role string) Neorm {
-	orm.Query = fmt.Sprintf("SET DEFAULT ROLE '%s' FOR '%s'@'%s'", role, orm._User, orm._Scope)
+	orm.setRaw(fmt.Sprintf("SET DEFAULT ROLE '%s' FOR '%s'@'%s'", role, orm._User, orm._Scope))
 
 	return *orm
 }
 
 func (orm *Neorm) FlushPrivileges() Neorm {
-	orm.Query = "FLUSH PRIVILEGES"
+	orm.setRaw("FLUSH PRIVILEGES")
 
 	return *orm
 }
 
 func (orm *Neorm) LockUserAccount(user, scope string) Neorm {
-	orm.Query = fmt.Sprintf("ALTER USER '%s'@'%s' ACCOUNT LOCK", user, scope)
+	orm.setRaw(fmt.Sprintf("ALTER USER '%s'@'%s' ACCOUNT LOCK", user, scope))
 
 	return *orm
 }
 
 func (orm *Neorm) PasswordExpiration(user, scope, expirationStr string) Neorm {
-	orm.Query = fmt.Sprintf("ALTER USER '%s'@'%s' PASSWORD EXPIRE %s", user, scope, expirationStr)
+	orm.setRaw(fmt.Sprintf("ALTER USER '%s'@'%s' PASSWORD EXPIRE %s", user, scope, expirationStr))
 
 	return *orm
 }
 
 // query builder:
 
-func (orm *Neorm) Select(columns interface{}) Neorm {
-	query := ""
-	orm._Table = ""
-	orm.Query = ""
-	orm._Type = "s"
+// statement gives the statement to be changed by a builder method, the query is rendered again on Finish or Execute.
+func (orm *Neorm) statement() *statement {
+	orm._Pending = true
+
+	return &orm._Statement
+}
+
+// start begins a new statement of the given kind, execType decides how Execute runs it.
+func (orm *Neorm) start(kind, execType string) *statement {
+	orm._Statement = statement{kind: kind}
+	orm._Type = execType
 	orm._Args = []any{}
+	orm.Query = ""
+	orm._ReturnsRows = false
+	orm._CacheTTL = 0
+
+	return orm.statement()
+}
+
+// setRaw replaces the statement with a query that is written as is, such as the user actions.
+func (orm *Neorm) setRaw(query string) {
+	orm._Statement = statement{kind: "ddl", raw: raw(query)}
+	orm._Pending = true
+	orm.Query = query
+}
+
+// value gives the fragment of a value: expressions are written with the syntax of the driver, anything else is bound.
+func (orm *Neorm) value(value interface{}) fragment {
+	if e, ok := value.(expr.Expr); ok {
+		return orm.expression(e)
+	}
+
+	return bound(orm.arg(value))
+}
+
+func (orm *Neorm) expression(e expr.Expr) fragment {
+	b := expr.NewBuilder(expr.Dialect(orm._Driver))
+	e.Build(b)
+
+	texts, args := b.Parts()
+
+	wrapped := make([]any, len(args))
+	for i, arg := range args {
+		wrapped[i] = orm.arg(arg)
+	}
+
+	return fragment{texts: append([]string(nil), texts...), args: wrapped}
+}
+
+// arg wraps go slices with pq.Array on postgresql so they can be bound as arrays, []byte stays bytea.
+func (orm *Neorm) arg(value interface{}) interface{} {
+	if orm._Driver != Postgresql {
+		return value
+	}
+
+	switch value.(type) {
+	case []string, []int, []int8, []int16, []int32, []int64,
+		[]uint, []uint16, []uint32, []uint64,
+		[]float32, []float64, []bool, []any:
+		return pq.Array(value)
+	default:
+		return value
+	}
+}
+
+func (orm *Neorm) Select(columns interface{}) Neorm {
+	st := orm.start("select", "s")
 
 	switch t := columns.(type) {
 	case string:
@@ -1239,489 +1584,363 @@ func (orm *Neorm) Select(columns interface{}) Neorm {
 			panic("If you want to use string in columns argument: it has to be '*'")
 		}
 
-		query = "SELECT * FROM"
+		st.columns = []fragment{raw("*")}
 	case []string:
-		query = "SELECT"
-
-		for i, column := range t {
-			if i+1 == len(t) {
-				query = fmt.Sprintf("%s %s FROM", query, column)
-			} else {
-				query = fmt.Sprintf("%s %s,", query, column)
+		for _, column := range t {
+			st.columns = append(st.columns, raw(column))
+		}
+	case []interface{}:
+		for _, column := range t {
+			switch c := column.(type) {
+			case string:
+				st.columns = append(st.columns, raw(c))
+			case expr.Expr:
+				st.columns = append(st.columns, orm.expression(c))
+			default:
+				panic("Error on Select method: columns should be either strings or expressions.")
 			}
 		}
 	}
 
-	orm.Query = query
-
 	return *orm
 }
 
 func (orm *Neorm) SelectFunction(function string, args ...interface{}) Neorm {
-	orm._Table = ""
-	orm._Type = "c"
-	orm.Query = ""
-	orm._Args = []any{}
-
-	orm.Query = fmt.Sprintf("SELECT * FROM %s(", function)
+	st := orm.start("select", "c")
 
+	values := make([]fragment, len(args))
 	for i, arg := range args {
-		if orm._Driver == Postgresql {
-			switch arg.(type) {
-			case []string, []int, []int8, []int16, []int32, []int64,
-				[]uint, []uint8, []uint16, []uint32, []uint64,
-				[]float32, []float64, []bool, []any:
-				orm._Args = append(orm._Args, pq.Array(arg))
-			default:
-				orm._Args = append(orm._Args, arg)
-			}
-		} else {
-			orm._Args = append(orm._Args, arg)
-		}
-
-		placeholder := orm.getPlaceHolder()
-
-		if i == 0 {
-			orm.Query = fmt.Sprintf("%s%s", orm.Query, placeholder)
-		} else {
-			orm.Query = fmt.Sprintf("%s, %s", orm.Query, placeholder)
-		}
+		values[i] = bound(orm.arg(arg))
 	}
-	orm.Query = orm.Query + ")"
+
+	st.columns = []fragment{raw("*")}
+	st.source = concat(function, "(", joinFragments(values, ", "), ")")
 
 	return *orm
 }
 
-func (orm *Neorm) CustomSelectQuery(query string) Neorm {
-	orm._Table = ""
-	orm._Type = "s"
-	orm._Args = []any{}
-
-	orm.Query = query
+// CustomSelectQuery starts a select query that is written by hand. params are the values of it's "?" placeholders,
+// or of it's named parameters such as ":id" as Params, maps or structs with db tags.
+func (orm *Neorm) CustomSelectQuery(query string, params ...interface{}) Neorm {
+	st := orm.start("raw", "s")
+	st.raw = orm.bindParams("CustomSelectQuery", query, params)
 
 	return *orm
 }
 
 func (orm *Neorm) Insert(columns []string, values interface{}) Neorm {
-	orm._Table = ""
-	orm.Query = ""
-	orm._Type = "i"
-	columnValues := "("
-	orm._Args = []any{}
+	st := orm.start("insert", "i")
 
-	for i, column := range columns {
-		if i == 0 {
-			columnValues = fmt.Sprintf("%s%s", columnValues, column)
-		} else {
-			columnValues = fmt.Sprintf("%s, %s", columnValues, column)
-		}
+	slice, ok := values.([]interface{})
+	if !ok {
+		panic("values argument should be a slice.")
 	}
 
-	columnValues = columnValues + ")"
+	st.insertColumns = append([]string(nil), columns...)
 
-	newValues := "("
+	for _, value := range slice {
+		st.values = append(st.values, orm.value(value))
+	}
 
-	if slice, ok := values.([]interface{}); ok {
-		for i, value := range slice {
-			if orm._Driver == Postgresql {
-				switch value.(type) {
-				case []string, []int, []int8, []int16, []int32, []int64,
-					[]uint, []uint8, []uint16, []uint32, []uint64,
-					[]float32, []float64, []bool, []any:
-					orm._Args = append(orm._Args, pq.Array(value))
-				default:
-					orm._Args = append(orm._Args, value)
-				}
-			} else {
-				orm._Args = append(orm._Args, value)
-			}
+	return *orm
+}
 
-			p := orm.getPlaceHolder()
+// InsertFrom inserts the rows that the given select query gives back, such as for archiving. The values of the
+// select query are bound together with the values of this one, columns can be empty to insert all of the columns.
+func (orm *Neorm) InsertFrom(table string, columns []string, selectBuilder Neorm) Neorm {
+	source := orm.subquery("InsertFrom", selectBuilder)
 
-			if i == 0 {
-				newValues = fmt.Sprintf("%s%s", newValues, p)
-			} else {
-				newValues = fmt.Sprintf("%s, %s", newValues, p)
-			}
+	st := orm.start("insertSelect", "u")
+	st.table = table
+	st.insertColumns = append([]string(nil), columns...)
+	st.source = source
+
+	return *orm
+}
+
+// CreateTableAs creates a table from the rows that the given select query gives back. It renders
+// "CREATE TABLE name AS SELECT ..." and "SELECT ... INTO name FROM ..." on microsoft sql server.
+func (orm *Neorm) CreateTableAs(name string, selectBuilder Neorm) Neorm {
+	source := orm.subquery("CreateTableAs", selectBuilder)
+
+	if orm._Driver == MicrosoftSqlServer {
+		if selectBuilder._Statement.kind != "select" {
+			panic("Error on CreateTableAs method: custom select queries cannot be used on microsoft sql server.")
 		}
-	} else {
-		panic("values argument should be a slice.")
+
+		into := selectBuilder
+		into._Driver = orm._Driver
+		into._Statement = selectBuilder._Statement.clone()
+		into._Statement.into = name
+
+		source = into.renderStatement()
 	}
 
-	orm.Query = fmt.Sprintf("INSERT INTO %s VALUES %s)", columnValues, newValues)
+	st := orm.start("createTableAs", "u")
+	st.table = name
+	st.source = source
 
 	return *orm
 }
 
-func (orm *Neorm) CustomInsertQuery(query string) Neorm {
-	orm._Table = ""
-	orm._Type = "i"
-	orm._Args = []any{}
+// subquery renders a select builder with the driver of this one, to be a part of it's query.
+func (orm *Neorm) subquery(method string, selectBuilder Neorm) fragment {
+	if selectBuilder._Type != "s" || (selectBuilder._Statement.kind != "select" && selectBuilder._Statement.kind != "raw") {
+		panic(fmt.Sprintf("Error on %s method: the builder should be a select query.", method))
+	}
 
-	orm.Query = query
+	selectBuilder._Driver = orm._Driver
+
+	return selectBuilder.renderStatement()
+}
+
+func (orm *Neorm) CustomInsertQuery(query string, params ...interface{}) Neorm {
+	st := orm.start("raw", "i")
+	st.raw = orm.bindParams("CustomInsertQuery", query, params)
 
 	return *orm
 }
 
+// GetFullQuery gives the query with it's values written as literals just like SQL, then it resets the builder.
 func (orm *Neorm) GetFullQuery() string {
-	QueryString := ""
-	switch orm._Driver {
-	case Postgresql:
-		QueryString = orm.Query
-		for i, arg := range orm._Args {
-			placeholderStr := fmt.Sprintf("$%d", i+1)
-			switch arg.(type) {
-			case []string, []int, []int8, []int16, []int32, []int64,
-				[]uint, []uint8, []uint16, []uint32, []uint64,
-				[]float32, []float64, []bool, []any:
-				QueryString = strings.Replace(QueryString, placeholderStr, fmt.Sprintf("%v", pq.Array(arg)), 1)
-			case string:
-				QueryString = strings.Replace(QueryString, placeholderStr, fmt.Sprintf("'%v'", arg), 1)
-			case int, int8, int16, int32, int64,
-				uint, uint8, uint16, uint32, uint64,
-				float32, float64, bool, any:
-				QueryString = strings.Replace(QueryString, placeholderStr, fmt.Sprintf("%v", arg), 1)
-			case nil:
-				QueryString = strings.Replace(QueryString, placeholderStr, "NULL", 1)
-			default:
-				QueryString = strings.Replace(QueryString, placeholderStr, fmt.Sprintf("%v", arg), 1)
-			}
-		}
-	case Mysql, Sqlite3:
-		QueryString = orm.Query
-		for _, arg := range orm._Args {
-			switch arg.(type) {
-			case string:
-				QueryString = strings.Replace(QueryString, "?", fmt.Sprintf("'%v'", arg), 1)
-			case int, int8, int16, int32, int64,
-				uint, uint8, uint16, uint32, uint64,
-				float32, float64, bool, any:
-				QueryString = strings.Replace(QueryString, "?", fmt.Sprintf("%v", arg), 1)
-			case nil:
-				QueryString = strings.Replace(QueryString, "?", "NULL", 1)
-			default:
-				QueryString = strings.Replace(QueryString, "?", fmt.Sprintf("%v", arg), 1)
-			}
-		}
-	case MicrosoftSqlServer:
-		QueryString = orm.Query
-		for i, arg := range orm._Args {
-			placeholderStr := fmt.Sprintf("@p%d", i+1)
-			switch arg.(type) {
-			case string:
-				QueryString = strings.Replace(QueryString, placeholderStr, fmt.Sprintf("'%v'", arg), 1)
-			case int, int8, int16, int32, int64,
-				uint, uint8, uint16, uint32, uint64,
-				float32, float64, bool, any:
-				QueryString = strings.Replace(QueryString, placeholderStr, fmt.Sprintf("%v", arg), 1)
-			case nil:
-				QueryString = strings.Replace(QueryString, placeholderStr, "NULL", 1)
-			default:
-				QueryString = strings.Replace(QueryString, placeholderStr, fmt.Sprintf("%v", arg), 1)
-			}
-		}
-	}
+	QueryString := orm.SQL()
 
 	orm._Args = []any{}
+	orm._Statement = statement{}
+	orm._Pending = false
+	orm._ReturnsRows = false
+	orm._CacheTTL = 0
 	orm.Query = ""
-	orm._Table = ""
 	orm._Type = ""
 
 	return QueryString
 }
 
-func (orm *Neorm) Returning(column string) Neorm {
-	if orm._Driver == Postgresql {
-		orm.Query = fmt.Sprintf("%s RETURNING %s", orm.Query, column)
+// Returning makes insert, update and delete queries give back the given columns of the affected rows,
+// they can be reached with Rows() after execution. It's rendered on Finish: "RETURNING" on postgresql,
+// sqlite (3.35+) and mariadb (10.5+, insert and delete only), "OUTPUT INSERTED.column" on microsoft sql server.
+func (orm *Neorm) Returning(columns ...string) Neorm {
+	if len(columns) == 0 {
+		panic("Error on Returning method: columns cannot be empty.")
+	}
+
+	switch orm._Statement.kind {
+	case "insert", "insertSelect", "update", "delete", "raw":
+	default:
+		panic("Error on Returning method: it can only be used with insert, update and delete queries.")
 	}
 
+	st := orm.statement()
+	st.returning = append(st.returning, columns...)
+
 	return *orm
 }
 
 func (orm *Neorm) Update() Neorm {
-	orm._Table = ""
-	orm._Type = "u"
-	orm.Query = "UPDATE"
-	orm._Args = []any{}
+	orm.start("update", "u")
 
 	return *orm
 }
 
-func (orm *Neorm) CustomUpdateQuery(query string) Neorm {
-	orm._Table = ""
-	orm._Type = "u"
-	orm._Args = []any{}
-
-	orm.Query = query
+func (orm *Neorm) CustomUpdateQuery(query string, params ...interface{}) Neorm {
+	st := orm.start("raw", "u")
+	st.raw = orm.bindParams("CustomUpdateQuery", query, params)
 
 	return *orm
 }
 
 func (orm *Neorm) Delete() Neorm {
-	orm._Table = ""
-	orm._Type = "u"
-	orm.Query = "DELETE FROM"
-	orm._Args = []any{}
+	orm.start("delete", "u")
 
 	return *orm
 }
 
-func (orm *Neorm) CustomDeleteQuery(query string) Neorm {
-	orm._Table = ""
-	orm._Type = "u"
-	orm._Args = []any{}
-
-	orm.Query = query
+func (orm *Neorm) CustomDeleteQuery(query string, params ...interface{}) Neorm {
+	st := orm.start("raw", "u")
+	st.raw = orm.bindParams("CustomDeleteQuery", query, params)
 
 	return *orm
 }
 
 func (orm *Neorm) Call(callType, procedure, resultAlias string, args ...interface{}) Neorm {
+	st := orm.start("call", "c")
 	orm._Procedure = procedure
-	orm._Type = "c"
-	orm._Table = ""
-	orm._Args = []any{}
+	orm._ResultAlias = ""
+
+	head := ""
 
 	switch callType {
 	case "procedure", "proc", "p", "pr":
-		orm.Query = fmt.Sprintf("CALL %s(", procedure)
+		head = "CALL"
 	case "function", "func", "f":
 		switch orm._Driver {
-		case Postgresql:
-			orm.Query = fmt.Sprintf("SELECT %s(", procedure)
 		case Mysql:
-			orm.Query = fmt.Sprintf("CALL %s(", procedure)
+			head = "CALL"
+		default:
+			head = "SELECT"
 		}
-
+	default:
+		panic("Error on Call method: callType should be either procedure or function.")
 	}
 
 	if resultAlias != "" {
-		if !strings.HasPrefix(resultAlias, "@") {
-			orm._ResultAlias = "@" + resultAlias
-		}
+		orm._ResultAlias = "@" + strings.TrimPrefix(resultAlias, "@")
 	}
 
+	values := make([]fragment, len(args))
 	for i, arg := range args {
-		if orm._Driver == Postgresql {
-			switch arg.(type) {
-			case []string, []int, []int8, []int16, []int32, []int64,
-				[]uint, []uint8, []uint16, []uint32, []uint64,
-				[]float32, []float64, []bool, []any:
-				orm._Args = append(orm._Args, pq.Array(arg))
-			default:
-				orm._Args = append(orm._Args, arg)
-			}
-		} else {
-			orm._Args = append(orm._Args, arg)
-		}
-
-		placeholder := orm.getPlaceHolder()
-
-		if i+1 != len(args) {
-			orm.Query = fmt.Sprintf("%s, %s", orm.Query, placeholder)
-		} else {
-			orm.Query = fmt.Sprintf("%s%s", orm.Query, placeholder)
-		}
+		values[i] = bound(orm.arg(arg))
 	}
 
-	orm.Query = orm.Query + ")"
+	st.raw = concat(fmt.Sprintf("%s %s(", head, procedure), joinFragments(values, ", "), ")")
 
 	return *orm
 }
 
+// Table sets the table of the query, it can be called anywhere in the chain.
 func (orm *Neorm) Table(table string) Neorm {
-	if strings.HasPrefix(orm.Query, "INSERT INTO") {
-		splittedString := strings.Split(orm.Query, " INTO ")
+	st := orm.statement()
 
-		orm.Query = fmt.Sprintf("%s INTO %s %s", splittedString[0], table, splittedString[1])
-	} else {
-		orm.Query = fmt.Sprintf("%s %s", orm.Query, table)
+	switch st.kind {
+	case "select", "count", "insert", "update", "delete":
+	default:
+		st.raw = concat(st.raw, " ", table)
 	}
 
+	st.table = table
+
 	return *orm
 }
 
-func (orm *Neorm) Where(column, mark string, value interface{}) Neorm {
-	if value != nil {
-		orm._Args = append(orm._Args, value)
+// addCondition adds a condition with the given WHERE, AND or OR connector, the first condition of the query is
+// always rendered with WHERE.
+func (orm *Neorm) addCondition(method, queryType string, expr fragment) *condition {
+	if queryType == "" {
+		queryType = "WHERE"
+	}
 
-		p := orm.getPlaceHolder()
+	QueryType := strings.ToUpper(queryType)
+	switch QueryType {
+	case "WHERE", "AND", "OR":
+	default:
+		panic(fmt.Sprintf("Invalid query type for %s method: it should be either WHERE, AND or OR.", method))
+	}
 
-		if strings.HasSuffix(orm.Query, " (") {
-			orm.Query = fmt.Sprintf("%s%s %s %s", orm.Query, column, mark, p)
-		} else {
-			orm.Query = fmt.Sprintf("%s WHERE %s %s %s", orm.Query, column, mark, p)
-		}
-	} else {
-		switch mark {
-		case "=":
-			if strings.HasSuffix(orm.Query, " (") {
-				orm.Query = fmt.Sprintf("%s%s IS NULL", orm.Query, column)
-			} else {
-				orm.Query = fmt.Sprintf("%s WHERE %s IS NULL", orm.Query, column)
-			}
-		case "!=", "<>":
-			if strings.HasSuffix(orm.Query, " (") {
-				orm.Query = fmt.Sprintf("%s%s IS NOT NULL", orm.Query, column)
-			} else {
-				orm.Query = fmt.Sprintf("%s WHERE %s IS NOT NULL", orm.Query, column)
-			}
-		default:
-			panic("Invalid operator for NULL value")
-		}
+	st := orm.statement()
+	st.conditions = append(st.conditions, condition{connector: QueryType, expr: expr})
+
+	return &st.conditions[len(st.conditions)-1]
+}
+
+// compare writes "column mark value" with a bound value or an expression, nil values become IS NULL or IS NOT NULL.
+func (orm *Neorm) compare(column, mark string, value interface{}) fragment {
+	if value != nil {
+		return concat(fmt.Sprintf("%s %s ", column, mark), orm.value(value))
 	}
 
+	switch mark {
+	case "=":
+		return raw(fmt.Sprintf("%s IS NULL", column))
+	case "!=", "<>":
+		return raw(fmt.Sprintf("%s IS NOT NULL", column))
+	default:
+		panic("Invalid operator for NULL value")
+	}
+}
+
+func (orm *Neorm) Where(column, mark string, value interface{}) Neorm {
+	orm.addCondition("Where", "WHERE", orm.compare(column, mark, value))
+
 	return *orm
 }
 
 func (orm *Neorm) WhereExpr(column, mark string, expr string) Neorm {
-	if strings.HasSuffix(orm.Query, " (") {
-		orm.Query = fmt.Sprintf("%s%s %s %s", orm.Query, column, mark, expr)
-	} else {
-		orm.Query = fmt.Sprintf("%s WHERE %s %s %s", orm.Query, column, mark, expr)
-	}
+	orm.addCondition("WhereExpr", "WHERE", raw(fmt.Sprintf("%s %s %s", column, mark, expr)))
 
 	return *orm
 }
 
 func (orm *Neorm) Or(column, mark string, value interface{}) Neorm {
-	if value != nil {
-		orm._Args = append(orm._Args, value)
-
-		p := orm.getPlaceHolder()
-
-		if strings.HasSuffix(orm.Query, " (") {
-			orm.Query = fmt.Sprintf("%s%s %s %s", orm.Query, column, mark, p)
-		} else {
-			orm.Query = fmt.Sprintf("%s OR %s %s %s", orm.Query, column, mark, p)
-		}
-	} else {
-		switch mark {
-		case "=":
-			if strings.HasSuffix(orm.Query, " (") {
-				orm.Query = fmt.Sprintf("%s%s IS NULL", orm.Query, column)
-			} else {
-				orm.Query = fmt.Sprintf("%s OR %s IS NULL", orm.Query, column)
-			}
-		case "!=", "<>":
-			if strings.HasSuffix(orm.Query, " (") {
-				orm.Query = fmt.Sprintf("%s%s IS NOT NULL", orm.Query, column)
-			} else {
-				orm.Query = fmt.Sprintf("%s OR %s IS NOT NULL", orm.Query, column)
-			}
-		default:
-			panic("Invalid operator for NULL value")
-		}
-	}
+	orm.addCondition("Or", "OR", orm.compare(column, mark, value))
 
 	return *orm
 }
 
 func (orm *Neorm) OrExpr(column, mark string, expr string) Neorm {
-	if strings.HasSuffix(orm.Query, " (") {
-		orm.Query = fmt.Sprintf("%s%s %s %s", orm.Query, column, mark, expr)
-	} else {
-		orm.Query = fmt.Sprintf("%s OR %s %s %s", orm.Query, column, mark, expr)
-	}
+	orm.addCondition("OrExpr", "OR", raw(fmt.Sprintf("%s %s %s", column, mark, expr)))
 
 	return *orm
 }
 
 func (orm *Neorm) And(column, mark string, value interface{}) Neorm {
-	if value != nil {
-		orm._Args = append(orm._Args, value)
+	orm.addCondition("And", "AND", orm.compare(column, mark, value))
 
-		p := orm.getPlaceHolder()
+	return *orm
+}
 
-		if strings.HasSuffix(orm.Query, " (") {
-			orm.Query = fmt.Sprintf("%s%s %s %s", orm.Query, column, mark, p)
-		} else {
-			orm.Query = fmt.Sprintf("%s AND %s %s %s", orm.Query, column, mark, p)
-		}
-	} else {
-		switch mark {
-		case "=":
-			if strings.HasSuffix(orm.Query, " (") {
-				orm.Query = fmt.Sprintf("%s%s IS NULL", orm.Query, column)
-			} else {
-				orm.Query = fmt.Sprintf("%s AND %s IS NULL", orm.Query, column)
-			}
-		case "!=", "<>":
-			if strings.HasSuffix(orm.Query, " (") {
-				orm.Query = fmt.Sprintf("%s%s IS NOT NULL", orm.Query, column)
-			} else {
-				orm.Query = fmt.Sprintf("%s AND %s IS NOT NULL", orm.Query, column)
-			}
-		default:
-			panic("Invalid operator for NULL value")
-		}
-	}
+func (orm *Neorm) AndExpr(column, mark string, expr string) Neorm {
+	orm.addCondition("AndExpr", "AND", raw(fmt.Sprintf("%s %s %s", column, mark, expr)))
 
 	return *orm
 }
 
-func (orm *Neorm) AndExpr(column, mark string, expr string) Neorm {
-	if strings.HasSuffix(orm.Query, " (") {
-		orm.Query = fmt.Sprintf("%s%s %s %s", orm.Query, column, mark, expr)
-	} else {
-		orm.Query = fmt.Sprintf("%s AND %s %s %s", orm.Query, column, mark, expr)
-	}
+// Condition adds a condition that is built with the expr package, such as
+// Condition("AND", expr.Gt(expr.Coalesce(expr.Col("discount"), 0), 10)).
+func (orm *Neorm) Condition(queryType string, condition expr.Expr) Neorm {
+	orm.addCondition("Condition", queryType, orm.expression(condition))
 
 	return *orm
 }
 
 func (orm *Neorm) Set(column string, value interface{}) Neorm {
-	var p string
+	st := orm.statement()
 
 	if value != nil {
-		if orm._Driver == Postgresql {
-			switch value.(type) {
-			case []string, []int, []int8, []int16, []int32, []int64,
-				[]uint, []uint8, []uint16, []uint32, []uint64,
-				[]float32, []float64, []bool, []any:
-				orm._Args = append(orm._Args, pq.Array(value))
-			default:
-				orm._Args = append(orm._Args, value)
-			}
-		} else {
-			orm._Args = append(orm._Args, value)
-		}
-		p = orm.getPlaceHolder()
-	} else {
-		p = "NULL"
-	}
-
-	if strings.Contains(orm.Query, "SET") {
-		orm.Query = fmt.Sprintf("%s, %s = %s", orm.Query, column, p)
+		st.set = append(st.set, concat(fmt.Sprintf("%s = ", column), orm.value(value)))
 	} else {
-		orm.Query = fmt.Sprintf("%s SET %s = %s", orm.Query, column, p)
+		st.set = append(st.set, raw(fmt.Sprintf("%s = NULL", column)))
 	}
 
 	return *orm
 }
 
 func (orm *Neorm) SetExpr(column, expr string) Neorm {
-	orm.Query = fmt.Sprintf("%s SET %s = %s", orm.Query, column, expr)
+	st := orm.statement()
+	st.set = append(st.set, raw(fmt.Sprintf("%s = %s", column, expr)))
 
 	return *orm
 }
 
+// Between completes the last condition, such as WhereExpr("age", "", "") followed by Between(18, 30).
 func (orm *Neorm) Between(first, second interface{}) Neorm {
-	firstPlaceHolder := orm.getPlaceHolder()
-	secondPlaceHolder := orm.getPlaceHolder()
+	st := orm.statement()
 
-	orm._Args = append(orm._Args, first, second)
+	if len(st.conditions) == 0 || st.conditions[len(st.conditions)-1].open || st.conditions[len(st.conditions)-1].close {
+		panic("Error on Between method: it should be called right after a condition.")
+	}
 
-	orm.Query = fmt.Sprintf("%s BETWEEN %s AND %s", orm.Query, firstPlaceHolder, secondPlaceHolder)
+	last := &st.conditions[len(st.conditions)-1]
+	last.expr = concat(last.expr)
+	last.expr.texts[len(last.expr.texts)-1] = strings.TrimRight(last.expr.texts[len(last.expr.texts)-1], " ")
+	last.expr = concat(last.expr, " BETWEEN ", orm.value(first), " AND ", orm.value(second))
 
 	return *orm
 }
 
-func (orm *Neorm) Like(queryType, column, operand, pattern string) Neorm {
-	if queryType == "" {
-		queryType = "WHERE"
+func likePattern(operand, pattern string) string {
+	switch strings.ToLower(pattern) {
+	case "all", "a", "contains", "c", "includes", "i":
+		return "%" + operand + "%"
+	case "start", "starts", "s", "begins", "b":
+		return operand + "%"
+	case "end", "ends", "e":
+		return "%" + operand
 	}
 
+	return operand
+}
+
+func (orm *Neorm) Like(queryType, column, operand, pattern string) Neorm {
 	if column == "" {
 		panic("Column cannot be empty.")
 	}
@@ -1730,155 +1949,383 @@ func (orm *Neorm) Like(queryType, column, operand, pattern string) Neorm {
 		panic("Operand cannot be empty.")
 	}
 
-	switch strings.ToLower(pattern) {
-	case "all", "a", "contains", "c", "includes", "i":
-		operand = "%" + operand + "%"
-	case "start", "starts", "s", "begins", "b":
-		operand = operand + "%"
-	case "end", "ends", "e":
-		operand = "%" + operand
+	orm.addCondition("Like", queryType, concat(fmt.Sprintf("%s LIKE ", column), bound(likePattern(operand, pattern))))
+
+	return *orm
+}
+
+func (orm *Neorm) NotLike(queryType, column, operand, pattern string) Neorm {
+	if column == "" {
+		panic("Column cannot be empty.")
 	}
 
-	QueryType := strings.ToUpper(queryType)
-	switch QueryType {
-	case "WHERE", "AND", "OR":
-		orm._Args = append(orm._Args, operand)
+	if operand == "" {
+		panic("Operand cannot be empty.")
+	}
 
-		placeholder := orm.getPlaceHolder()
+	orm.addCondition("NotLike", queryType, concat(fmt.Sprintf("%s NOT LIKE ", column), bound(likePattern(operand, pattern))))
 
-		if strings.HasSuffix(orm.Query, " (") {
-			orm.Query = fmt.Sprintf("%s%s LIKE %s", orm.Query, column, placeholder)
-		} else {
-			orm.Query = fmt.Sprintf("%s %s %s LIKE %s", orm.Query, QueryType, column, placeholder)
+	return *orm
+}
+
+// full-text search:
+
+// TextSearchConfiguration is the postgresql text search configuration that Match and CreateFulltextIndex use,
+// they have to be the same for the index to be used.
+var TextSearchConfiguration = "simple"
+
+// Match adds a full-text search condition, it's joined with AND if the query already has a WHERE clause.
+// mode can be empty for the default of the driver, or:
+//
+// mysql: "natural", "boolean" or "expansion".
+// postgresql: "websearch", "plain", "phrase" or "raw" (to_tsquery syntax).
+// microsoft sql server: "contains" or "freetext".
+//
+// On sqlite it matches against the fts5 table given to Table, columns narrow the search with a column filter.
+func (orm *Neorm) Match(columns []string, query, mode string) Neorm {
+	if len(columns) == 0 {
+		panic("Error on Match method: columns cannot be empty.")
+	}
+
+	switch orm._Driver {
+	case Postgresql:
+		orm.addCondition("Match", "AND", concat(textSearchDocument(columns), " @@ ", textSearchQuery(mode, query)))
+	case Sqlite3:
+		c := orm.addCondition("Match", "AND", bound(fmt.Sprintf("{%s} : (%s)", strings.Join(columns, " "), query)))
+		c.matchesTable = true
+	case MicrosoftSqlServer:
+		function := ""
+
+		switch strings.ToLower(mode) {
+		case "freetext":
+			function = "FREETEXT"
+		case "", "contains":
+			function = "CONTAINS"
+		default:
+			panic(fmt.Sprintf("Error on Match method: unknown mode for microsoft sql server: %s", mode))
 		}
+
+		orm.addCondition("Match", "AND", concat(fmt.Sprintf("%s((%s), ", function, strings.Join(columns, ", ")), bound(query), ")"))
 	default:
-		panic("Invalid query type for Like method: it should be either WHERE, AND or OR.")
+		orm.addCondition("Match", "AND", concat(fmt.Sprintf("MATCH (%s) AGAINST (", strings.Join(columns, ", ")), bound(query), mysqlSearchModifier(mode), ")"))
 	}
 
 	return *orm
 }
 
-func (orm *Neorm) NotLike(queryType, column, operand, pattern string) Neorm {
-	if queryType == "" {
-		queryType = "WHERE"
+// OrderByRelevance orders the rows by how well they match the query, best matches first. It uses ts_rank on postgresql,
+// the relevance of MATCH on mysql and the rank column of fts5 on sqlite. It's not supported on microsoft sql server.
+func (orm *Neorm) OrderByRelevance(columns []string, query, mode string) Neorm {
+	switch orm._Driver {
+	case Postgresql:
+		orm.appendOrdering(concat("ts_rank(", textSearchDocument(columns), ", ", textSearchQuery(mode, query), ") DESC"))
+	case Sqlite3:
+		orm.appendOrdering(raw("rank"))
+	case MicrosoftSqlServer:
+		panic("OrderByRelevance is not supported on microsoft sql server, use CONTAINSTABLE with a custom query instead.")
+	default:
+		orm.appendOrdering(concat(fmt.Sprintf("MATCH (%s) AGAINST (", strings.Join(columns, ", ")), bound(query), mysqlSearchModifier(mode), ") DESC"))
 	}
 
-	if column == "" {
-		panic("Column cannot be empty.")
+	return *orm
+}
+
+// sqliteFulltextIndex creates an external content fts5 table, rebuilds it from the rows of table and adds the
+// triggers that keep it up to date. The old values are removed with the "delete" command of fts5.
+func sqliteFulltextIndex(name, table string, columns []string) string {
+	list := strings.Join(columns, ", ")
+	newValues := "new." + strings.Join(columns, ", new.")
+	oldValues := "old." + strings.Join(columns, ", old.")
+
+	insert := fmt.Sprintf("INSERT INTO %s(rowid, %s) VALUES (new.rowid, %s);", name, list, newValues)
+	remove := fmt.Sprintf("INSERT INTO %s(%s, rowid, %s) VALUES ('delete', old.rowid, %s);", name, name, list, oldValues)
+
+	return strings.Join([]string{
+		fmt.Sprintf("CREATE VIRTUAL TABLE %s USING fts5(%s, content='%s')", name, list, strings.ReplaceAll(table, "'", "''")),
+		fmt.Sprintf("INSERT INTO %s(%s) VALUES ('rebuild')", name, name),
+		fmt.Sprintf("CREATE TRIGGER %s_ai AFTER INSERT ON %s BEGIN %s END", name, table, insert),
+		fmt.Sprintf("CREATE TRIGGER %s_ad AFTER DELETE ON %s BEGIN %s END", name, table, remove),
+		fmt.Sprintf("CREATE TRIGGER %s_au AFTER UPDATE ON %s BEGIN %s %s END", name, table, remove, insert),
+	}, "; ")
+}
+
+// textSearchConfiguration is TextSearchConfiguration as a string literal.
+func textSearchConfiguration() string {
+	return "'" + strings.ReplaceAll(TextSearchConfiguration, "'", "''") + "'"
+}
+
+func textSearchDocument(columns []string) string {
+	document := ""
+
+	for i, column := range columns {
+		if i == 0 {
+			document = fmt.Sprintf("coalesce(%s, '')", column)
+		} else {
+			document = fmt.Sprintf("%s || ' ' || coalesce(%s, '')", document, column)
+		}
 	}
 
-	if operand == "" {
-		panic("Operand cannot be empty.")
+	return fmt.Sprintf("to_tsvector(%s, %s)", textSearchConfiguration(), document)
+}
+
+func textSearchQuery(mode, query string) fragment {
+	function := ""
+
+	switch strings.ToLower(mode) {
+	case "", "websearch":
+		function = "websearch_to_tsquery"
+	case "plain", "natural":
+		function = "plainto_tsquery"
+	case "phrase":
+		function = "phraseto_tsquery"
+	case "raw", "boolean":
+		function = "to_tsquery"
+	default:
+		panic(fmt.Sprintf("Unknown full-text search mode for postgresql: %s", mode))
 	}
 
-	switch strings.ToLower(pattern) {
-	case "all", "a", "contains", "c", "includes", "i":
-		operand = "%" + operand + "%"
-	case "start", "starts", "s", "begins", "b":
-		operand = operand + "%"
-	case "end", "ends", "e":
-		operand = "%" + operand
+	return concat(fmt.Sprintf("%s(%s, ", function, textSearchConfiguration()), bound(query), ")")
+}
+
+func mysqlSearchModifier(mode string) string {
+	switch strings.ToLower(mode) {
+	case "", "natural":
+		return " IN NATURAL LANGUAGE MODE"
+	case "boolean", "websearch":
+		return " IN BOOLEAN MODE"
+	case "expansion":
+		return " WITH QUERY EXPANSION"
+	default:
+		panic(fmt.Sprintf("Unknown full-text search mode for mysql: %s", mode))
 	}
+}
 
-	QueryType := strings.ToUpper(queryType)
-	switch QueryType {
-	case "WHERE", "AND", "OR":
-		orm._Args = append(orm._Args, operand)
+// json queries:
 
-		placeholder := orm.getPlaceHolder()
+// SelectJSON adds the value at the given json path of a column to the selected columns.
+// Paths are written like "$.address.city" or "$.tags[0]".
+func (orm *Neorm) SelectJSON(column, path, alias string) Neorm {
+	if orm._Statement.kind != "select" {
+		panic("Error on SelectJSON method: it can only be used with select queries.")
+	}
+
+	st := orm.statement()
+	st.columns = append(st.columns, concat(orm.jsonExtract(column, path), " AS ", alias))
+
+	return *orm
+}
+
+// WhereJSON compares the value at the given json path of a column, such as WhereJSON("data", "$.address.city", "=", "Istanbul").
+func (orm *Neorm) WhereJSON(column, path, mark string, value interface{}) Neorm {
+	orm.compareJSON("WHERE", column, path, mark, value)
+
+	return *orm
+}
+
+func (orm *Neorm) AndJSON(column, path, mark string, value interface{}) Neorm {
+	orm.compareJSON("AND", column, path, mark, value)
+
+	return *orm
+}
+
+func (orm *Neorm) OrJSON(column, path, mark string, value interface{}) Neorm {
+	orm.compareJSON("OR", column, path, mark, value)
 
-		if strings.HasSuffix(orm.Query, " (") {
-			orm.Query = fmt.Sprintf("%s%s NOT LIKE %s", orm.Query, column, placeholder)
+	return *orm
+}
+
+// JSONContains checks if a json column contains the given value. On postgresql and mysql value can be anything
+// that can be marshalled to json, on sqlite and microsoft sql server it should be a scalar value that is searched
+// in a json array.
+func (orm *Neorm) JSONContains(queryType, column string, value interface{}) Neorm {
+	var expr fragment
+
+	switch orm._Driver {
+	case Postgresql, Mysql:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			panic(fmt.Sprintf("Error on JSONContains method: value cannot be marshalled to json: %s", err))
+		}
+
+		if orm._Driver == Postgresql {
+			expr = concat(fmt.Sprintf("%s @> ", column), bound(string(encoded)), "::jsonb")
 		} else {
-			orm.Query = fmt.Sprintf("%s %s %s NOT LIKE %s", orm.Query, QueryType, column, placeholder)
+			expr = concat(fmt.Sprintf("JSON_CONTAINS(%s, ", column), bound(string(encoded)), ")")
+		}
+	case Sqlite3, MicrosoftSqlServer:
+		switch value.(type) {
+		case string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		default:
+			panic("Error on JSONContains method: only scalar values are supported on sqlite and microsoft sql server.")
+		}
+
+		if orm._Driver == Sqlite3 {
+			expr = concat(fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(%s) WHERE json_each.value = ", column), bound(value), ")")
+		} else {
+			expr = concat(fmt.Sprintf("EXISTS (SELECT 1 FROM OPENJSON(%s) WHERE value = ", column), bound(value), ")")
 		}
-	default:
-		panic("Invalid query type for NotLike method: it should be either WHERE, AND or OR.")
 	}
 
+	orm.addCondition("JSONContains", queryType, expr)
+
 	return *orm
 }
 
-func (orm *Neorm) In(inType string, column string, values []any) Neorm {
-	switch strings.ToLower(inType) {
-	case "where":
-		orm.Query = fmt.Sprintf("%s WHERE %s IN(", orm.Query, column)
-	case "and":
-		orm.Query = fmt.Sprintf("%s AND %s IN(", orm.Query, column)
-	case "or":
-		orm.Query = fmt.Sprintf("%s OR %s IN(", orm.Query, column)
+// JSONHasKey checks if a json column has the given top level key.
+func (orm *Neorm) JSONHasKey(queryType, column, key string) Neorm {
+	var expr fragment
+	path := fmt.Sprintf("$.\"%s\"", strings.ReplaceAll(key, "\"", "\\\""))
+
+	switch orm._Driver {
+	case Postgresql:
+		expr = concat(fmt.Sprintf("%s ? ", column), bound(key))
+	case Mysql:
+		expr = concat(fmt.Sprintf("JSON_CONTAINS_PATH(%s, 'one', ", column), bound(path), ")")
+	case Sqlite3:
+		expr = concat(fmt.Sprintf("json_type(%s, ", column), bound(path), ") IS NOT NULL")
+	case MicrosoftSqlServer:
+		expr = concat(fmt.Sprintf("(JSON_VALUE(%s, ", column), bound(path), fmt.Sprintf(") IS NOT NULL OR JSON_QUERY(%s, ", column), bound(path), ") IS NOT NULL)")
 	}
 
-	for i, value := range values {
-		orm._Args = append(orm._Args, value)
+	orm.addCondition("JSONHasKey", queryType, expr)
 
-		p := orm.getPlaceHolder()
+	return *orm
+}
 
-		if i == 0 {
-			orm.Query = fmt.Sprintf("%s%s", orm.Query, p)
-		} else {
-			orm.Query = fmt.Sprintf("%s, %s", orm.Query, p)
+func (orm *Neorm) compareJSON(queryType, column, path, mark string, value interface{}) {
+	extract := orm.jsonExtract(column, path)
+
+	if value == nil {
+		switch mark {
+		case "=":
+			orm.addCondition("WhereJSON", queryType, concat(extract, " IS NULL"))
+		case "!=", "<>":
+			orm.addCondition("WhereJSON", queryType, concat(extract, " IS NOT NULL"))
+		default:
+			panic("Invalid operator for NULL value")
 		}
+
+		return
 	}
 
-	orm.Query = orm.Query + ")"
+	// postgresql extracts values as text, they should be casted to be compared with numbers or booleans:
+	if orm._Driver == Postgresql {
+		switch value.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			extract = concat("(", extract, ")::numeric")
+		case bool:
+			extract = concat("(", extract, ")::boolean")
+		}
+	}
 
-	return *orm
+	orm.addCondition("WhereJSON", queryType, concat(extract, fmt.Sprintf(" %s ", mark), bound(value)))
 }
 
-func (orm *Neorm) NotIn(inType string, column string, values []any) Neorm {
-	switch strings.ToLower(inType) {
-	case "where":
-		orm.Query = fmt.Sprintf("%s WHERE %s NOT IN(", orm.Query, column)
-	case "and":
-		orm.Query = fmt.Sprintf("%s AND %s NOT IN(", orm.Query, column)
-	case "or":
-		orm.Query = fmt.Sprintf("%s OR %s NOT IN(", orm.Query, column)
+// jsonExtract binds the path and returns the expression that extracts it's value as scalar.
+func (orm *Neorm) jsonExtract(column, path string) fragment {
+	segments := jsonPathSegments(path)
+
+	if orm._Driver == Postgresql {
+		return concat(fmt.Sprintf("%s #>> ", column), bound(pq.Array(segments)))
 	}
 
-	for i, value := range values {
-		orm._Args = append(orm._Args, value)
+	if !strings.HasPrefix(path, "$") {
+		path = "$." + path
+	}
 
-		p := orm.getPlaceHolder()
+	switch orm._Driver {
+	case Sqlite3:
+		return concat(fmt.Sprintf("json_extract(%s, ", column), bound(path), ")")
+	case MicrosoftSqlServer:
+		return concat(fmt.Sprintf("JSON_VALUE(%s, ", column), bound(path), ")")
+	default:
+		return concat(fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(%s, ", column), bound(path), "))")
+	}
+}
 
-		if i == 0 {
-			orm.Query = fmt.Sprintf("%s%s", orm.Query, p)
-		} else {
-			orm.Query = fmt.Sprintf("%s, %s", orm.Query, p)
+// jsonPathSegments splits a path like "$.tags[0].name" into it's keys and indexes: ["tags", "0", "name"].
+func jsonPathSegments(path string) []string {
+	path = strings.TrimPrefix(path, "$")
+
+	var segments []string
+
+	for _, part := range strings.Split(path, ".") {
+		for part != "" {
+			open := strings.Index(part, "[")
+			if open == -1 {
+				segments = append(segments, strings.Trim(part, "\""))
+				break
+			}
+
+			if open > 0 {
+				segments = append(segments, strings.Trim(part[:open], "\""))
+			}
+
+			close := strings.Index(part, "]")
+			if close == -1 || close < open {
+				panic(fmt.Sprintf("Invalid json path: %s", path))
+			}
+
+			segments = append(segments, part[open+1:close])
+			part = part[close+1:]
 		}
 	}
 
-	orm.Query = orm.Query + ")"
+	return segments
+}
+
+func (orm *Neorm) inList(column, operator string, values []any) fragment {
+	list := make([]fragment, len(values))
+	for i, value := range values {
+		list[i] = orm.value(value)
+	}
+
+	return concat(fmt.Sprintf("%s %s(", column, operator), joinFragments(list, ", "), ")")
+}
+
+func (orm *Neorm) In(inType string, column string, values []any) Neorm {
+	orm.addCondition("In", inType, orm.inList(column, "IN", values))
+
+	return *orm
+}
+
+func (orm *Neorm) NotIn(inType string, column string, values []any) Neorm {
+	orm.addCondition("NotIn", inType, orm.inList(column, "NOT IN", values))
 
 	return *orm
 }
 
+func (orm *Neorm) addJoin(kind, table, on string) {
+	st := orm.statement()
+	st.joins = append(st.joins, joinClause{kind: kind, table: table, on: on})
+}
+
 func (orm *Neorm) InnerJoin(table string, left string, mark string, right string) Neorm {
-	orm.Query = fmt.Sprintf("%s INNER JOIN %s ON %s %s %s", orm.Query, table, left, mark, right)
+	orm.addJoin("INNER JOIN", table, fmt.Sprintf("%s %s %s", left, mark, right))
 
 	return *orm
 }
 
 func (orm *Neorm) LeftJoin(table string, left string, mark string, right string) Neorm {
-	orm.Query = fmt.Sprintf("%s LEFT JOIN %s ON %s %s %s", orm.Query, table, left, mark, right)
+	orm.addJoin("LEFT JOIN", table, fmt.Sprintf("%s %s %s", left, mark, right))
 
 	return *orm
 }
 
 func (orm *Neorm) RightJoin(table string, left string, mark string, right string) Neorm {
-	orm.Query = fmt.Sprintf("%s INNER JOIN %s ON %s %s %s", orm.Query, table, left, mark, right)
+	orm.addJoin("RIGHT JOIN", table, fmt.Sprintf("%s %s %s", left, mark, right))
 
 	return *orm
 }
 
 func (orm *Neorm) NaturalJoin(table string) Neorm {
-	orm.Query = fmt.Sprintf("%s NATURAL JOIN %s", orm.Query, table)
+	orm.addJoin("NATURAL JOIN", table, "")
 
 	return *orm
 }
 
 func (orm *Neorm) CrossJoin(table string) Neorm {
-	orm.Query = fmt.Sprintf("%s CROSS JOIN %s", orm.Query, table)
+	orm.addJoin("CROSS JOIN", table, "")
 
 	return *orm
 }
@@ -1888,7 +2335,8 @@ func (orm *Neorm) OpenParenthesis(parenthesisType string) Neorm {
 
 	switch upperType {
 	case "WHERE", "AND", "OR":
-		orm.Query = fmt.Sprintf("%s %s (", orm.Query, upperType)
+		st := orm.statement()
+		st.conditions = append(st.conditions, condition{connector: upperType, open: true})
 	default:
 		panic("For now, only WHERE, AND, OR operators supported for opening parenthesis.")
 	}
@@ -1897,11 +2345,22 @@ func (orm *Neorm) OpenParenthesis(parenthesisType string) Neorm {
 }
 
 func (orm *Neorm) CloseParenthesis() Neorm {
-	if !strings.ContainsAny(orm.Query, "(") {
+	opened := 0
+
+	for _, c := range orm._Statement.conditions {
+		if c.open {
+			opened++
+		} else if c.close {
+			opened--
+		}
+	}
+
+	if opened <= 0 {
 		panic("You're not opened a parenthesis, you cannot close one!")
 	}
 
-	orm.Query = orm.Query + ")"
+	st := orm.statement()
+	st.conditions = append(st.conditions, condition{close: true})
 
 	return *orm
 }
@@ -1909,17 +2368,9 @@ func (orm *Neorm) CloseParenthesis() Neorm {
 func (orm *Neorm) OrderBy(column, ordering string) Neorm {
 	switch ordering {
 	case "ASC", "Asc", "asc":
-		if strings.Contains(orm.Query, "ORDER BY") {
-			orm.Query = fmt.Sprintf("%s, %s ASC", orm.Query, column)
-		} else {
-			orm.Query = fmt.Sprintf("%s ORDER BY %s ASC", orm.Query, column)
-		}
+		orm.appendOrdering(raw(fmt.Sprintf("%s ASC", column)))
 	case "DESC", "Desc", "desc":
-		if strings.Contains(orm.Query, "ORDER BY") {
-			orm.Query = fmt.Sprintf("%s, %s DESC", orm.Query, column)
-		} else {
-			orm.Query = fmt.Sprintf("%s ORDER BY %s DESC", orm.Query, column)
-		}
+		orm.appendOrdering(raw(fmt.Sprintf("%s DESC", column)))
 	default:
 		panic("Error on OrderBy method: ordering should be either ASC or DESC.")
 	}
@@ -1927,82 +2378,219 @@ func (orm *Neorm) OrderBy(column, ordering string) Neorm {
 	return *orm
 }
 
+// OrderByField orders rows by the position of the column's value in values, the values that aren't in the list go
+// last. Values are bound as parameters. It renders a CASE expression on every driver, FIELD() of mysql would put the
+// values that aren't in the list first.
 func (orm *Neorm) OrderByField(column string, values []string) Neorm {
 	if len(values) == 0 {
 		panic("Error on OrderByField method: values cannot be empty.")
 	}
 
-	if strings.Contains(orm.Query, "ORDER BY") {
-		orm.Query = fmt.Sprintf("%s, FIELD(%s", orm.Query, column)
-	} else {
-		orm.Query = fmt.Sprintf("%s ORDER BY FIELD(%s", orm.Query, column)
-	}
+	ordering := []interface{}{"CASE"}
 
-	for _, value := range values {
-		orm.Query = fmt.Sprintf("%s, %s", orm.Query, value)
+	for i, value := range values {
+		ordering = append(ordering, fmt.Sprintf(" WHEN %s = ", column), bound(value), fmt.Sprintf(" THEN %d", i))
 	}
 
-	orm.Query = orm.Query + ")"
+	orm.appendOrdering(concat(append(ordering, fmt.Sprintf(" ELSE %d END", len(values)))...))
 
 	return *orm
 }
 
 func (orm *Neorm) OrderRandom() Neorm {
-	orm.Query = fmt.Sprintf("%s ORDER BY RAND()", orm.Query)
+	switch orm._Driver {
+	case Postgresql, Sqlite3:
+		orm.appendOrdering(raw("RANDOM()"))
+	case MicrosoftSqlServer:
+		orm.appendOrdering(raw("NEWID()"))
+	default:
+		orm.appendOrdering(raw("RAND()"))
+	}
+
+	return *orm
+}
+
+// OrderByNulls works like OrderBy but also decides where the null values go, nulls should be either "FIRST" or "LAST".
+// postgresql and sqlite support it natively, on mysql and microsoft sql server it's emulated with an extra ordering.
+func (orm *Neorm) OrderByNulls(column, ordering, nulls string) Neorm {
+	ordering = strings.ToUpper(ordering)
+	nulls = strings.ToUpper(nulls)
+
+	if ordering != "ASC" && ordering != "DESC" {
+		panic("Error on OrderByNulls method: ordering should be either ASC or DESC.")
+	}
+
+	if nulls != "FIRST" && nulls != "LAST" {
+		panic("Error on OrderByNulls method: nulls should be either FIRST or LAST.")
+	}
+
+	switch orm._Driver {
+	case Postgresql, Sqlite3:
+		orm.appendOrdering(raw(fmt.Sprintf("%s %s NULLS %s", column, ordering, nulls)))
+	default:
+		if nulls == "FIRST" {
+			orm.appendOrdering(raw(fmt.Sprintf("CASE WHEN %s IS NULL THEN 0 ELSE 1 END, %s %s", column, column, ordering)))
+		} else {
+			orm.appendOrdering(raw(fmt.Sprintf("CASE WHEN %s IS NULL THEN 1 ELSE 0 END, %s %s", column, column, ordering)))
+		}
+	}
+
+	return *orm
+}
+
+// OrderByExpr orders the rows by an expression of the expr package.
+func (orm *Neorm) OrderByExpr(e expr.Expr, ordering string) Neorm {
+	switch strings.ToUpper(ordering) {
+	case "ASC", "DESC":
+		orm.appendOrdering(concat(orm.expression(e), " ", strings.ToUpper(ordering)))
+	default:
+		panic("Error on OrderByExpr method: ordering should be either ASC or DESC.")
+	}
 
 	return *orm
 }
 
+func (orm *Neorm) appendOrdering(ordering fragment) {
+	st := orm.statement()
+	st.orderBy = append(st.orderBy, ordering)
+}
+
 func (orm *Neorm) GroupBy(columns ...string) Neorm {
 	if len(columns) == 0 {
 		panic("Error on GroupBy method: columns cannot be empty.")
 	}
 
-	for i, column := range columns {
-		if i == 0 && !strings.Contains(orm.Query, "GROUP BY") {
-			orm.Query = fmt.Sprintf("%s GROUP BY %s", orm.Query, column)
-		} else {
-			orm.Query = fmt.Sprintf("%s, %s", orm.Query, column)
-		}
+	st := orm.statement()
+
+	for _, column := range columns {
+		st.groupBy = append(st.groupBy, raw(column))
 	}
 
 	return *orm
 }
 
-func (orm *Neorm) Count(table string) Neorm {
-	orm._Args = []any{}
-	orm._Table = ""
-	orm._Type = "l"
+// row locking:
+
+// ForUpdate locks the selected rows until the end of the transaction, it renders "FOR UPDATE" on postgresql and
+// mysql and the UPDLOCK and ROWLOCK table hints on microsoft sql server. sqlite locks the whole database on writes,
+// so it's a no-op there. Queries with a locking clause can only be executed inside a transaction.
+func (orm *Neorm) ForUpdate() Neorm {
+	orm.setLock("ForUpdate", "UPDATE")
+
+	return *orm
+}
+
+// ForShare locks the selected rows against updates but lets the other transactions read them, it renders
+// "FOR SHARE" on postgresql and mysql 8 and the HOLDLOCK and ROWLOCK table hints on microsoft sql server.
+func (orm *Neorm) ForShare() Neorm {
+	orm.setLock("ForShare", "SHARE")
+
+	return *orm
+}
 
-	orm.Query = fmt.Sprintf("SELECT COUNT(*) AS length FROM %s", table)
+// SkipLocked skips the rows that are locked by the other transactions instead of waiting for them,
+// it's the READPAST table hint on microsoft sql server.
+func (orm *Neorm) SkipLocked() Neorm {
+	orm.setLockWait("SkipLocked", "SKIP LOCKED")
 
 	return *orm
 }
 
+// NoWait makes the query fail instead of waiting if any of the rows is locked by another transaction.
+func (orm *Neorm) NoWait() Neorm {
+	orm.setLockWait("NoWait", "NOWAIT")
+
+	return *orm
+}
+
+// Of narrows the lock to the given tables of a query with joins.
+func (orm *Neorm) Of(tables ...string) Neorm {
+	if len(tables) == 0 {
+		panic("Error on Of method: tables cannot be empty.")
+	}
+
+	st := orm.lockedStatement("Of")
+	st.lockOf = append(st.lockOf, tables...)
+
+	return *orm
+}
+
+func (orm *Neorm) lockedStatement(method string) *statement {
+	if orm._Statement.kind != "select" {
+		panic(fmt.Sprintf("Error on %s method: row locking can only be used with select queries.", method))
+	}
+
+	return orm.statement()
+}
+
+func (orm *Neorm) setLock(method, lock string) {
+	orm.lockedStatement(method).lock = lock
+}
+
+func (orm *Neorm) setLockWait(method, wait string) {
+	orm.lockedStatement(method).lockWait = wait
+}
+
+// GroupByExpr groups the rows by an expression of the expr package.
+func (orm *Neorm) GroupByExpr(e expr.Expr) Neorm {
+	st := orm.statement()
+	st.groupBy = append(st.groupBy, orm.expression(e))
+
+	return *orm
+}
+
+// GroupByTimeBucket groups the rows by the time bucket of the column, such as the day or the month of it. The same
+// bucket can be selected with expr.DateTrunc(unit, expr.Col(column)).
+func (orm *Neorm) GroupByTimeBucket(column string, unit expr.Unit) Neorm {
+	if column == "" {
+		panic("Error on GroupByTimeBucket method: column cannot be empty.")
+	}
+
+	return orm.GroupByExpr(expr.DateTrunc(unit, expr.Col(column)))
+}
+
+func (orm *Neorm) Count(table string) Neorm {
+	st := orm.start("count", "l")
+	st.table = table
+
+	return *orm
+}
+
+// Limit and Offset are rendered on Finish (or Execute) with the syntax of the current driver. Offset is only for
+// select queries, rendering panics for the limits that the driver doesn't have, such as the updates of postgresql.
 func (orm *Neorm) Limit(limit int) Neorm {
-	orm.Query = fmt.Sprintf("%s LIMIT %d", orm.Query, limit)
+	st := orm.statement()
+	st.limit = limit
+	st.hasLimit = true
 
 	return *orm
 }
 
 func (orm *Neorm) Offset(offset int) Neorm {
-	orm.Query = fmt.Sprintf("%s OFFSET %d", orm.Query, offset)
+	st := orm.statement()
+	st.offset = offset
+	st.hasOffset = true
 
 	return *orm
 }
 
-func (orm *Neorm) CustomQuery(query string) Neorm {
+// CustomQuery replaces the query, the way Execute runs it doesn't change. params are bound just like the other
+// custom queries.
+func (orm *Neorm) CustomQuery(query string, params ...interface{}) Neorm {
+	orm._Statement = statement{kind: "raw", raw: orm.bindParams("CustomQuery", query, params)}
+	orm._Pending = true
 	orm.Query = query
 
 	return *orm
 }
 
+// Finish renders the query, the builder methods can be called in any order before it.
 func (orm *Neorm) Finish() Neorm {
-	if strings.HasPrefix(orm.Query, "CREATE TABLE") {
-		orm.Query = fmt.Sprintf("%s);", orm.Query)
-	} else {
-		orm.Query = fmt.Sprintf("%s;", orm.Query)
+	if orm._Pending {
+		orm.render()
 	}
 
+	orm.Query = fmt.Sprintf("%s;", orm.Query)
+
 	return *orm
 }
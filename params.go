@@ -0,0 +1,395 @@
+package neormgo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// parameters of custom queries:
+
+// Params are the named parameters of a custom query, such as Params{"id": 1} for "WHERE id = :id".
+type Params map[string]interface{}
+
+// bindParams binds the values of a custom query, they're written with the placeholders of the driver when the query
+// is rendered. If the query has "?" placeholders, params are their values in order. Otherwise they're the values
+// of the named parameters such as ":id", which can be Params, maps with string keys and structs that are named by
+// their db tags. Slices are expanded into a list of values for IN, an empty slice panics since "IN (NULL)" turns
+// NOT IN into a condition that never matches. "??" is an escaped question mark, such as the json operators of
+// postgresql. A query without params is kept as is, apart from it's "??" which are still unescaped.
+func (orm *Neorm) bindParams(method, query string, params []interface{}) fragment {
+	tokens := scanQuery(query, orm._Driver)
+
+	if len(params) == 0 {
+		var b strings.Builder
+
+		written := 0
+		for _, token := range tokens {
+			if token.kind == '!' {
+				b.WriteString(query[written:token.start])
+				b.WriteString("?")
+				written = token.end
+			}
+		}
+
+		b.WriteString(query[written:])
+
+		return raw(b.String())
+	}
+
+	positional := 0
+	for _, token := range tokens {
+		if token.kind == '?' {
+			positional++
+		}
+	}
+
+	values := map[string]interface{}{}
+
+	if positional == 0 {
+		for _, param := range params {
+			if err := collectParams(values, reflect.ValueOf(param)); err != nil {
+				panic(fmt.Sprintf("Error on %s method: %s.", method, err))
+			}
+		}
+	} else if positional != len(params) {
+		panic(fmt.Sprintf("Error on %s method: the query has %d placeholders but %d values are given.", method, positional, len(params)))
+	}
+
+	var parts []any
+	written, next := 0, 0
+
+	for _, token := range tokens {
+		switch {
+		case token.kind == '!':
+			parts = append(parts, query[written:token.start], "?")
+		case token.kind == '?':
+			parts = append(parts, query[written:token.start], orm.value(params[next]))
+			next++
+		case positional == 0:
+			name := query[token.start+1 : token.end]
+
+			value, ok := values[name]
+			if !ok {
+				panic(fmt.Sprintf("Error on %s method: missing parameter %q.", method, name))
+			}
+
+			parts = append(parts, query[written:token.start], orm.namedValue(method, name, value))
+		default:
+			continue
+		}
+
+		written = token.end
+	}
+
+	parts = append(parts, query[written:])
+
+	return concat(parts...)
+}
+
+// Rebind converts the "?" placeholders of a query to the placeholders of the driver, such as "$1" on postgresql and
+// "@p1" on microsoft sql server. "??" is turned into a question mark that isn't a placeholder, the literals and the
+// comments of the query are kept as is.
+func (orm *Neorm) Rebind(query string) string {
+	var b strings.Builder
+
+	written, next := 0, 0
+
+	for _, token := range scanQuery(query, orm._Driver) {
+		switch token.kind {
+		case '!':
+			b.WriteString(query[written:token.start])
+			b.WriteString("?")
+		case '?':
+			next++
+			b.WriteString(query[written:token.start])
+
+			switch orm._Driver {
+			case Postgresql:
+				b.WriteString(fmt.Sprintf("$%d", next))
+			case MicrosoftSqlServer:
+				b.WriteString(fmt.Sprintf("@p%d", next))
+			default:
+				b.WriteString("?")
+			}
+		default:
+			continue
+		}
+
+		written = token.end
+	}
+
+	b.WriteString(query[written:])
+
+	return b.String()
+}
+
+// token is a placeholder of a query at query[start:end]: '?' is a positional one, '!' an escaped question mark and
+// ':' a named one.
+type token struct {
+	start, end int
+	kind       byte
+}
+
+// scanQuery finds the placeholders of a query that are outside of it's literals and comments.
+func scanQuery(query string, driver Driver) []token {
+	var tokens []token
+
+	for i := 0; i < len(query); {
+		if end := skipQuoted(query, i, driver); end != i {
+			i = end
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(query[i:], "??"):
+			tokens = append(tokens, token{start: i, end: i + 2, kind: '!'})
+			i += 2
+		case query[i] == '?':
+			tokens = append(tokens, token{start: i, end: i + 1, kind: '?'})
+			i++
+		case strings.HasPrefix(query[i:], "::"):
+			// the casts of postgresql, such as "created_at::date":
+			for i < len(query) && query[i] == ':' {
+				i++
+			}
+		case query[i] == ':' && i > 0 && (query[i-1] == '[' || query[i-1] >= '0' && query[i-1] <= '9'):
+			// the array slices of postgresql, such as "tags[1:n]":
+			i++
+		case query[i] == ':':
+			end := i + 1
+			for end < len(query) && isNameChar(query[end], end == i+1) {
+				end++
+			}
+
+			if end != i+1 {
+				tokens = append(tokens, token{start: i, end: end, kind: ':'})
+			}
+
+			i = max(end, i+1)
+		default:
+			i++
+		}
+	}
+
+	return tokens
+}
+
+// namedValue binds a value, or each element of a slice separated with commas. Empty slices panic, since an empty
+// subquery such as "SELECT NULL WHERE 1=0" gives a text column on postgresql, which can't be compared to numbers.
+func (orm *Neorm) namedValue(method, name string, value interface{}) fragment {
+	rv := reflect.ValueOf(value)
+
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return orm.value(value)
+	}
+
+	if rv.Len() == 0 {
+		panic(fmt.Sprintf("Error on %s method: the slice of parameter %q is empty.", method, name))
+	}
+
+	elements := make([]fragment, rv.Len())
+	for i := range elements {
+		elements[i] = orm.value(rv.Index(i).Interface())
+	}
+
+	return joinFragments(elements, ", ")
+}
+
+func collectParams(values map[string]interface{}, rv reflect.Value) error {
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return fmt.Errorf("params cannot be nil")
+		}
+
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("the keys of the params should be strings")
+		}
+
+		iter := rv.MapRange()
+		for iter.Next() {
+			values[iter.Key().String()] = iter.Value().Interface()
+		}
+	case reflect.Struct:
+		collectFields(values, rv)
+	default:
+		return fmt.Errorf("params should be Params, a map or a struct, not %s", rv.Type())
+	}
+
+	return nil
+}
+
+// collectFields names the fields of a struct by their db tags, or by their lower case names if they don't have one.
+// Fields tagged with "-" are skipped and the fields of the embedded structs are collected as their own.
+func collectFields(values map[string]interface{}, rv reflect.Value) {
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("db"), ",")
+
+		if tag == "-" {
+			continue
+		}
+
+		if field.Anonymous && tag == "" && field.Type.Kind() == reflect.Struct {
+			collectFields(values, rv.Field(i))
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if tag == "" {
+			tag = strings.ToLower(field.Name)
+		}
+
+		values[tag] = rv.Field(i).Interface()
+	}
+}
+
+// hasKeyword tells if a custom query has the keyword at it's top level, outside of it's parentheses, literals and
+// comments. The WHERE of "SELECT * FROM (SELECT * FROM t WHERE a = 1) x" belongs to the subquery, so it doesn't count.
+// The words of the keyword can be separated with any whitespace.
+func hasKeyword(query, keyword string, driver Driver) bool {
+	words := strings.Fields(keyword)
+	depth := 0
+
+	for i := 0; i < len(query); {
+		if end := skipQuoted(query, i, driver); end != i {
+			i = end
+			continue
+		}
+
+		switch {
+		case query[i] == '(':
+			depth++
+		case query[i] == ')':
+			depth--
+		case depth == 0 && keywordAt(query, i, words):
+			return true
+		}
+
+		i++
+	}
+
+	return false
+}
+
+func keywordAt(query string, i int, words []string) bool {
+	if i > 0 && isNameChar(query[i-1], false) {
+		return false
+	}
+
+	for n, word := range words {
+		if n > 0 {
+			start := i
+			for i < len(query) && isSpace(query[i]) {
+				i++
+			}
+
+			if i == start {
+				return false
+			}
+		}
+
+		if len(query)-i < len(word) || !strings.EqualFold(query[i:i+len(word)], word) {
+			return false
+		}
+
+		i += len(word)
+	}
+
+	return i == len(query) || !isNameChar(query[i], false)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// dollarTag gives the tag of the dollar quoted string that starts at i, such as "$$" or "$body$". A tag doesn't
+// start with a digit, so placeholders like "$1" aren't taken as one.
+func dollarTag(query string, i int) string {
+	if query[i] != '$' {
+		return ""
+	}
+
+	end := i + 1
+	for end < len(query) && isNameChar(query[end], end == i+1) {
+		end++
+	}
+
+	if end < len(query) && query[end] == '$' {
+		return query[i : end+1]
+	}
+
+	return ""
+}
+
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || !first && c >= '0' && c <= '9'
+}
+
+// skipQuoted gives the end of the string literal, quoted identifier or comment that starts at i, or i itself if
+// nothing starts there. Backslashes escape quotes in the strings of mysql and the E'...' strings of postgresql, the
+// dollar quoted strings of postgresql such as "$$ ... $$" and "$body$ ... $body$" are skipped as a whole.
+func skipQuoted(query string, i int, driver Driver) int {
+	if driver == Postgresql && (i == 0 || !isNameChar(query[i-1], false) && query[i-1] != '$') {
+		if (query[i] == 'E' || query[i] == 'e') && strings.HasPrefix(query[i+1:], "'") {
+			for j := i + 2; j < len(query); j++ {
+				switch query[j] {
+				case '\\':
+					j++
+				case '\'':
+					return j + 1
+				}
+			}
+
+			return len(query)
+		}
+
+		if tag := dollarTag(query, i); tag != "" {
+			if end := strings.Index(query[i+len(tag):], tag); end != -1 {
+				return i + len(tag) + end + len(tag)
+			}
+
+			return len(query)
+		}
+	}
+
+	switch {
+	case query[i] == '\'' || query[i] == '"' || query[i] == '`':
+		quote := query[i]
+
+		for j := i + 1; j < len(query); j++ {
+			switch {
+			case query[j] == '\\' && driver == Mysql && quote != '`':
+				j++
+			case query[j] == quote:
+				return j + 1
+			}
+		}
+
+		return len(query)
+	case strings.HasPrefix(query[i:], "--"):
+		if end := strings.IndexByte(query[i:], '\n'); end != -1 {
+			return i + end + 1
+		}
+
+		return len(query)
+	case strings.HasPrefix(query[i:], "/*"):
+		if end := strings.Index(query[i+2:], "*/"); end != -1 {
+			return i + 2 + end + 2
+		}
+
+		return len(query)
+	}
+
+	return i
+}
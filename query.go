@@ -0,0 +1,385 @@
+package neormgo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Necoo33/neormgo/v2/expr"
+)
+
+// immutable query builders:
+
+// DB is a connection handle that is safe for concurrent use. Queries started from it are Query values, every method
+// of a Query returns a new one and leaves the receiver untouched, so the same base query can be extended and executed
+// from different goroutines.
+type DB struct {
+	base Neorm
+}
+
+// Tx is a DB bound to a transaction, queries started from it run inside that transaction.
+type Tx struct {
+	DB
+}
+
+type Query struct {
+	orm Neorm
+}
+
+func Open(connString string, driver string) (*DB, error) {
+	orm := Neorm{}
+
+	connected, err := orm.Connect(connString, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DB{base: connected}, nil
+}
+
+// Pool returns the underlying connection pool.
+func (db *DB) Pool() *sql.DB {
+	return db.base.Pool
+}
+
+// Driver returns the driver of the connection, for the queries that differ between databases.
+func (db *DB) Driver() Driver {
+	return db.base._Driver
+}
+
+func (db *DB) Rebind(query string) string {
+	return db.base.Rebind(query)
+}
+
+func (db *DB) Close() error {
+	return db.base.Pool.Close()
+}
+
+// WithCache returns a copy of the handle whose queries use the given store for Cache.
+func (db *DB) WithCache(store CacheStore) *DB {
+	copied := *db
+	copied.base.CacheStore = store
+
+	return &copied
+}
+
+// Neorm returns a mutable builder on the same connection, for the schema, table and user builders.
+func (db *DB) Neorm() Neorm {
+	return db.base
+}
+
+func (db *DB) Lock(ctx context.Context, key string) (*AppLock, error) {
+	return db.base.Lock(ctx, key)
+}
+
+func (db *DB) TryLock(ctx context.Context, key string) (*AppLock, bool, error) {
+	return db.base.TryLock(ctx, key)
+}
+
+func (db *DB) Begin() (*Tx, error) {
+	if db.base.Pool == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	tx, err := db.base.Pool.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	copied := *db
+	copied.base.Tx = tx
+	copied.base._TxTables = &txTables{}
+
+	return &Tx{DB: copied}, nil
+}
+
+// Commit commits the transaction and invalidates the cached rows of the tables it has written to.
+func (tx *Tx) Commit() error {
+	if err := tx.base.Tx.Commit(); err != nil {
+		return err
+	}
+
+	tx.base.invalidateCommitted(tx.base._TxTables)
+
+	return nil
+}
+
+func (tx *Tx) Rollback() error {
+	return tx.base.Tx.Rollback()
+}
+
+func (db *DB) start(build func(orm *Neorm)) Query {
+	q := Query{orm: db.base}
+	build(&q.orm)
+
+	return q
+}
+
+func (db *DB) Select(columns interface{}) Query {
+	return db.start(func(orm *Neorm) { orm.Select(columns) })
+}
+
+func (db *DB) SelectFunction(function string, args ...interface{}) Query {
+	return db.start(func(orm *Neorm) { orm.SelectFunction(function, args...) })
+}
+
+func (db *DB) CustomSelectQuery(query string, params ...interface{}) Query {
+	return db.start(func(orm *Neorm) { orm.CustomSelectQuery(query, params...) })
+}
+
+func (db *DB) Insert(columns []string, values interface{}) Query {
+	return db.start(func(orm *Neorm) { orm.Insert(columns, values) })
+}
+
+func (db *DB) InsertFrom(table string, columns []string, selectQuery Query) Query {
+	return db.start(func(orm *Neorm) { orm.InsertFrom(table, columns, selectQuery.orm) })
+}
+
+func (db *DB) CreateTableAs(name string, selectQuery Query) Query {
+	return db.start(func(orm *Neorm) { orm.CreateTableAs(name, selectQuery.orm) })
+}
+
+func (db *DB) CustomInsertQuery(query string, params ...interface{}) Query {
+	return db.start(func(orm *Neorm) { orm.CustomInsertQuery(query, params...) })
+}
+
+func (db *DB) Update() Query {
+	return db.start(func(orm *Neorm) { orm.Update() })
+}
+
+func (db *DB) CustomUpdateQuery(query string, params ...interface{}) Query {
+	return db.start(func(orm *Neorm) { orm.CustomUpdateQuery(query, params...) })
+}
+
+func (db *DB) Delete() Query {
+	return db.start(func(orm *Neorm) { orm.Delete() })
+}
+
+func (db *DB) CustomDeleteQuery(query string, params ...interface{}) Query {
+	return db.start(func(orm *Neorm) { orm.CustomDeleteQuery(query, params...) })
+}
+
+func (db *DB) Count(table string) Query {
+	return db.start(func(orm *Neorm) { orm.Count(table) })
+}
+
+func (db *DB) Call(callType, procedure, resultAlias string, args ...interface{}) Query {
+	return db.start(func(orm *Neorm) { orm.Call(callType, procedure, resultAlias, args...) })
+}
+
+// with copies the query, including it's slices, and applies the builder method to the copy.
+func (q Query) with(build func(orm *Neorm)) Query {
+	copied := q
+
+	copied.orm._Args = append([]any(nil), q.orm._Args...)
+	copied.orm._Statement = q.orm._Statement.clone()
+
+	build(&copied.orm)
+
+	return copied
+}
+
+func (q Query) Table(table string) Query {
+	return q.with(func(orm *Neorm) { orm.Table(table) })
+}
+
+func (q Query) Where(column, mark string, value interface{}) Query {
+	return q.with(func(orm *Neorm) { orm.Where(column, mark, value) })
+}
+
+func (q Query) WhereExpr(column, mark string, expr string) Query {
+	return q.with(func(orm *Neorm) { orm.WhereExpr(column, mark, expr) })
+}
+
+func (q Query) Or(column, mark string, value interface{}) Query {
+	return q.with(func(orm *Neorm) { orm.Or(column, mark, value) })
+}
+
+func (q Query) OrExpr(column, mark string, expr string) Query {
+	return q.with(func(orm *Neorm) { orm.OrExpr(column, mark, expr) })
+}
+
+func (q Query) And(column, mark string, value interface{}) Query {
+	return q.with(func(orm *Neorm) { orm.And(column, mark, value) })
+}
+
+func (q Query) AndExpr(column, mark string, expr string) Query {
+	return q.with(func(orm *Neorm) { orm.AndExpr(column, mark, expr) })
+}
+
+func (q Query) Condition(queryType string, condition expr.Expr) Query {
+	return q.with(func(orm *Neorm) { orm.Condition(queryType, condition) })
+}
+
+func (q Query) Set(column string, value interface{}) Query {
+	return q.with(func(orm *Neorm) { orm.Set(column, value) })
+}
+
+func (q Query) SetExpr(column, expr string) Query {
+	return q.with(func(orm *Neorm) { orm.SetExpr(column, expr) })
+}
+
+func (q Query) Between(first, second interface{}) Query {
+	return q.with(func(orm *Neorm) { orm.Between(first, second) })
+}
+
+func (q Query) Like(queryType, column, operand, pattern string) Query {
+	return q.with(func(orm *Neorm) { orm.Like(queryType, column, operand, pattern) })
+}
+
+func (q Query) NotLike(queryType, column, operand, pattern string) Query {
+	return q.with(func(orm *Neorm) { orm.NotLike(queryType, column, operand, pattern) })
+}
+
+func (q Query) In(inType string, column string, values []any) Query {
+	return q.with(func(orm *Neorm) { orm.In(inType, column, values) })
+}
+
+func (q Query) NotIn(inType string, column string, values []any) Query {
+	return q.with(func(orm *Neorm) { orm.NotIn(inType, column, values) })
+}
+
+func (q Query) Match(columns []string, query, mode string) Query {
+	return q.with(func(orm *Neorm) { orm.Match(columns, query, mode) })
+}
+
+func (q Query) OrderByRelevance(columns []string, query, mode string) Query {
+	return q.with(func(orm *Neorm) { orm.OrderByRelevance(columns, query, mode) })
+}
+
+func (q Query) SelectJSON(column, path, alias string) Query {
+	return q.with(func(orm *Neorm) { orm.SelectJSON(column, path, alias) })
+}
+
+func (q Query) WhereJSON(column, path, mark string, value interface{}) Query {
+	return q.with(func(orm *Neorm) { orm.WhereJSON(column, path, mark, value) })
+}
+
+func (q Query) AndJSON(column, path, mark string, value interface{}) Query {
+	return q.with(func(orm *Neorm) { orm.AndJSON(column, path, mark, value) })
+}
+
+func (q Query) OrJSON(column, path, mark string, value interface{}) Query {
+	return q.with(func(orm *Neorm) { orm.OrJSON(column, path, mark, value) })
+}
+
+func (q Query) JSONContains(queryType, column string, value interface{}) Query {
+	return q.with(func(orm *Neorm) { orm.JSONContains(queryType, column, value) })
+}
+
+func (q Query) JSONHasKey(queryType, column, key string) Query {
+	return q.with(func(orm *Neorm) { orm.JSONHasKey(queryType, column, key) })
+}
+
+func (q Query) InnerJoin(table string, left string, mark string, right string) Query {
+	return q.with(func(orm *Neorm) { orm.InnerJoin(table, left, mark, right) })
+}
+
+func (q Query) LeftJoin(table string, left string, mark string, right string) Query {
+	return q.with(func(orm *Neorm) { orm.LeftJoin(table, left, mark, right) })
+}
+
+func (q Query) RightJoin(table string, left string, mark string, right string) Query {
+	return q.with(func(orm *Neorm) { orm.RightJoin(table, left, mark, right) })
+}
+
+func (q Query) NaturalJoin(table string) Query {
+	return q.with(func(orm *Neorm) { orm.NaturalJoin(table) })
+}
+
+func (q Query) CrossJoin(table string) Query {
+	return q.with(func(orm *Neorm) { orm.CrossJoin(table) })
+}
+
+func (q Query) OpenParenthesis(parenthesisType string) Query {
+	return q.with(func(orm *Neorm) { orm.OpenParenthesis(parenthesisType) })
+}
+
+func (q Query) CloseParenthesis() Query {
+	return q.with(func(orm *Neorm) { orm.CloseParenthesis() })
+}
+
+func (q Query) OrderBy(column, ordering string) Query {
+	return q.with(func(orm *Neorm) { orm.OrderBy(column, ordering) })
+}
+
+func (q Query) OrderByField(column string, values []string) Query {
+	return q.with(func(orm *Neorm) { orm.OrderByField(column, values) })
+}
+
+func (q Query) OrderRandom() Query {
+	return q.with(func(orm *Neorm) { orm.OrderRandom() })
+}
+
+func (q Query) OrderByNulls(column, ordering, nulls string) Query {
+	return q.with(func(orm *Neorm) { orm.OrderByNulls(column, ordering, nulls) })
+}
+
+func (q Query) OrderByExpr(e expr.Expr, ordering string) Query {
+	return q.with(func(orm *Neorm) { orm.OrderByExpr(e, ordering) })
+}
+
+func (q Query) GroupByExpr(e expr.Expr) Query {
+	return q.with(func(orm *Neorm) { orm.GroupByExpr(e) })
+}
+
+func (q Query) GroupByTimeBucket(column string, unit expr.Unit) Query {
+	return q.with(func(orm *Neorm) { orm.GroupByTimeBucket(column, unit) })
+}
+
+func (q Query) GroupBy(columns ...string) Query {
+	return q.with(func(orm *Neorm) { orm.GroupBy(columns...) })
+}
+
+func (q Query) Limit(limit int) Query {
+	return q.with(func(orm *Neorm) { orm.Limit(limit) })
+}
+
+func (q Query) Offset(offset int) Query {
+	return q.with(func(orm *Neorm) { orm.Offset(offset) })
+}
+
+func (q Query) ForUpdate() Query {
+	return q.with(func(orm *Neorm) { orm.ForUpdate() })
+}
+
+func (q Query) ForShare() Query {
+	return q.with(func(orm *Neorm) { orm.ForShare() })
+}
+
+func (q Query) SkipLocked() Query {
+	return q.with(func(orm *Neorm) { orm.SkipLocked() })
+}
+
+func (q Query) NoWait() Query {
+	return q.with(func(orm *Neorm) { orm.NoWait() })
+}
+
+func (q Query) Of(tables ...string) Query {
+	return q.with(func(orm *Neorm) { orm.Of(tables...) })
+}
+
+func (q Query) Returning(columns ...string) Query {
+	return q.with(func(orm *Neorm) { orm.Returning(columns...) })
+}
+
+func (q Query) Cache(ttl time.Duration, tables ...string) Query {
+	return q.with(func(orm *Neorm) { orm.Cache(ttl, tables...) })
+}
+
+func (q Query) Invalidates(tables ...string) Query {
+	return q.with(func(orm *Neorm) { orm.Invalidates(tables...) })
+}
+
+// Execute runs the query on a copy of it, so the query can be executed again or concurrently.
+func (q Query) Execute() (Result, error) {
+	copied := q.with(func(orm *Neorm) {})
+
+	return copied.orm.ExecuteResult()
+}
+
+// SQL gives the query with it's values written as literals, see Neorm.SQL.
+func (q Query) SQL() string {
+	return q.orm.SQL()
+}
@@ -0,0 +1,549 @@
+// Package queue is a durable job queue that keeps it's jobs in a table of the database.
+//
+// Workers claim jobs with "SELECT ... FOR UPDATE SKIP LOCKED" (table hints on microsoft sql server), so any number
+// of them can work on the same queue. sqlite doesn't have row locks, jobs are claimed there with a conditional
+// update that only one of the workers can win.
+//
+// A claimed job is hidden from the other workers until it's visibility timeout ends, if the worker dies before
+// finishing it, the job is claimed again after that. Failed jobs are retried with backoff until they reach the
+// maximum attempts, then they're kept in the dead state until they're retried by hand.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Necoo33/neormgo/v2"
+)
+
+const (
+	StatusReady   = "ready"
+	StatusRunning = "running"
+	StatusDead    = "dead"
+)
+
+// ErrClaimLost is returned by Complete and Fail when the job isn't held by the worker anymore, since it's visibility
+// timeout has ended and another worker has claimed it.
+var ErrClaimLost = errors.New("the claim of the job is lost")
+
+// Options of a queue, zero values are replaced with the defaults.
+type Options struct {
+	// Table is the table of the jobs, "neorm_jobs" by default.
+	Table string
+	// MaxAttempts is the count of runs before a job is dead, 5 by default.
+	MaxAttempts int
+	// VisibilityTimeout is how long a claimed job is hidden from the other workers, 5 minutes by default.
+	VisibilityTimeout time.Duration
+	// PollInterval is how long a worker waits when there isn't any job to claim, 1 second by default.
+	PollInterval time.Duration
+	// Backoff gives the delay before the next run of a job that failed the given count of times,
+	// it doubles from 1 second up to 1 hour by default.
+	Backoff func(attempts int) time.Duration
+}
+
+// Job is a claimed job, times are kept in the table as unix milliseconds.
+type Job struct {
+	ID        int64
+	Queue     string
+	Payload   []byte
+	Status    string
+	Attempts  int
+	RunAt     time.Time
+	LastError string
+	CreatedAt time.Time
+}
+
+// Decode unmarshals the json payload of the job into v.
+func (job Job) Decode(v interface{}) error {
+	return json.Unmarshal(job.Payload, v)
+}
+
+// Handler runs a job, a returned error makes the job retried later.
+type Handler func(ctx context.Context, job Job) error
+
+type Queue struct {
+	db      *neormgo.DB
+	options Options
+}
+
+func New(db *neormgo.DB, options Options) *Queue {
+	if options.Table == "" {
+		options.Table = "neorm_jobs"
+	}
+
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = 5
+	}
+
+	if options.VisibilityTimeout <= 0 {
+		options.VisibilityTimeout = 5 * time.Minute
+	}
+
+	if options.PollInterval <= 0 {
+		options.PollInterval = time.Second
+	}
+
+	if options.Backoff == nil {
+		options.Backoff = DefaultBackoff
+	}
+
+	return &Queue{db: db, options: options}
+}
+
+// DefaultBackoff doubles the delay from 1 second for every failed attempt, up to 1 hour.
+func DefaultBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	if attempts > 12 {
+		return time.Hour
+	}
+
+	delay := time.Second << (attempts - 1)
+	if delay > time.Hour {
+		return time.Hour
+	}
+
+	return delay
+}
+
+// CreateTable creates the table of the jobs and it's index if they don't exist. On microsoft sql server,
+// which doesn't support "IF NOT EXISTS", it should be called only once.
+func (q *Queue) CreateTable(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	driver := q.db.Driver()
+	table := q.db.Neorm()
+
+	table.CreateTable(q.options.Table)
+	if driver != neormgo.MicrosoftSqlServer {
+		table = table.IfNotExist()
+	}
+
+	table.AddColumn("id")
+
+	switch driver {
+	case neormgo.Postgresql:
+		table.Type("BIGSERIAL")
+		table.PrimaryKey()
+	case neormgo.Sqlite3:
+		table.Type("INTEGER")
+		table.PrimaryKey()
+		table.CustomKeyword("AUTOINCREMENT")
+	case neormgo.MicrosoftSqlServer:
+		table.Type("BIGINT")
+		table.CustomKeyword("IDENTITY(1,1)")
+		table.PrimaryKey()
+	default:
+		table.Type("BIGINT")
+		table.AutoIncrement()
+		table.PrimaryKey()
+	}
+
+	payloadType := "TEXT"
+	if driver == neormgo.MicrosoftSqlServer {
+		payloadType = "NVARCHAR(MAX)"
+	}
+
+	table.AddColumn("queue")
+	table.Type("VARCHAR(255)")
+	table.NotNull()
+	table.AddColumn("payload")
+	table.Type(payloadType)
+	table.NotNull()
+	table.AddColumn("status")
+	table.Type("VARCHAR(16)")
+	table.NotNull()
+	table.AddColumn("attempts")
+	table.Type("INT")
+	table.NotNull()
+	table.Default(0)
+	table.AddColumn("run_at")
+	table.Type("BIGINT")
+	table.NotNull()
+	table.AddColumn("locked_until")
+	table.Type("BIGINT")
+	table.NotNull()
+	table.Default(0)
+	table.AddColumn("last_error")
+	table.Type(payloadType)
+	table.Null()
+	table.AddColumn("created_at")
+	table.Type("BIGINT")
+	table.NotNull()
+
+	if driver == neormgo.Mysql {
+		table.Index([]string{"queue", "status", "run_at"})
+	}
+
+	table.Finish()
+
+	if err := table.QueryDrop(); err != nil {
+		return err
+	}
+
+	if driver == neormgo.Mysql {
+		return nil
+	}
+
+	index := q.db.Neorm()
+
+	if driver == neormgo.MicrosoftSqlServer {
+		index.CustomQuery(fmt.Sprintf("CREATE INDEX %s_claim ON %s (queue, status, run_at)", q.options.Table, q.options.Table))
+	} else {
+		index.CustomQuery(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_claim ON %s (queue, status, run_at)", q.options.Table, q.options.Table))
+	}
+
+	index.Finish()
+
+	return index.QueryDrop()
+}
+
+// Enqueue adds a job to the given queue, payload is marshalled to json. The job isn't claimed before runAt,
+// a zero runAt means now. It returns the id of the job.
+func (q *Queue) Enqueue(ctx context.Context, queue string, payload interface{}, runAt time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("payload cannot be marshalled to json: %w", err)
+	}
+
+	now := time.Now()
+	if runAt.IsZero() {
+		runAt = now
+	}
+
+	insert := q.db.Insert([]string{"queue", "payload", "status", "attempts", "run_at", "locked_until", "created_at"},
+		[]interface{}{queue, string(encoded), StatusReady, 0, runAt.UnixMilli(), int64(0), now.UnixMilli()}).Table(q.options.Table)
+
+	if q.db.Driver() == neormgo.Postgresql {
+		insert = insert.Returning("id")
+	}
+
+	result, err := insert.Execute()
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(result.LastInsertId, 10, 64)
+}
+
+// Work claims and runs the jobs of the given queue until ctx is done. It can be called from many goroutines
+// or processes to run more workers.
+func (q *Queue) Work(ctx context.Context, queue string, handler Handler) error {
+	for {
+		job, err := q.Claim(ctx, queue)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(q.options.PollInterval):
+			}
+
+			continue
+		}
+
+		if job.Attempts > q.options.MaxAttempts {
+			// the last attempt timed out without being finished:
+			if err := q.kill(*job, "visibility timeout exceeded"); err != nil && !errors.Is(err, ErrClaimLost) {
+				return err
+			}
+
+			continue
+		}
+
+		// the job belongs to the worker that claimed it later, that worker finishes it:
+		if err := q.run(ctx, handler, *job); err != nil && !errors.Is(err, ErrClaimLost) {
+			return err
+		}
+	}
+}
+
+func (q *Queue) run(ctx context.Context, handler Handler, job Job) error {
+	runCtx, cancel := context.WithTimeout(ctx, q.options.VisibilityTimeout)
+	defer cancel()
+
+	handlerErr := handler(runCtx, job)
+	if handlerErr == nil {
+		return q.Complete(job)
+	}
+
+	return q.Fail(job, handlerErr)
+}
+
+// Claim takes the next job of the queue that is due and hides it from the other workers for the visibility
+// timeout. It returns nil if there isn't any.
+func (q *Queue) Claim(ctx context.Context, queue string) (*Job, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if q.db.Driver() == neormgo.Sqlite3 {
+		return q.claimOptimistic(queue)
+	}
+
+	tx, err := q.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixMilli()
+
+	result, err := q.due(&tx.DB, queue, now).ForUpdate().SkipLocked().Execute()
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if len(result.Rows) == 0 {
+		return nil, tx.Rollback()
+	}
+
+	job, err := decodeJob(result.Rows[0])
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	_, err = tx.Update().Table(q.options.Table).
+		Set("status", StatusRunning).
+		Set("locked_until", now+q.options.VisibilityTimeout.Milliseconds()).
+		SetExpr("attempts", "attempts + 1").
+		Where("id", "=", job.ID).
+		Execute()
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	job.Status = StatusRunning
+	job.Attempts++
+
+	return &job, nil
+}
+
+// claimOptimistic claims a job with an update that only succeeds if nobody else claimed it since it was selected.
+func (q *Queue) claimOptimistic(queue string) (*Job, error) {
+	for {
+		now := time.Now().UnixMilli()
+
+		result, err := q.due(q.db, queue, now).Execute()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(result.Rows) == 0 {
+			return nil, nil
+		}
+
+		job, err := decodeJob(result.Rows[0])
+		if err != nil {
+			return nil, err
+		}
+
+		updated, err := q.db.Update().Table(q.options.Table).
+			Set("status", StatusRunning).
+			Set("locked_until", now+q.options.VisibilityTimeout.Milliseconds()).
+			Set("attempts", job.Attempts+1).
+			Where("id", "=", job.ID).
+			And("attempts", "=", job.Attempts).
+			And("locked_until", "=", toInt64(result.Rows[0]["locked_until"])).
+			Execute()
+		if err != nil {
+			return nil, err
+		}
+
+		if updated.RowsAffected == 1 {
+			job.Status = StatusRunning
+			job.Attempts++
+
+			return &job, nil
+		}
+	}
+}
+
+// due selects the next job that can be claimed: a ready job whose time has come, or a running one whose
+// visibility timeout has ended.
+func (q *Queue) due(db *neormgo.DB, queue string, now int64) neormgo.Query {
+	return db.Select("*").Table(q.options.Table).
+		Where("queue", "=", queue).
+		In("AND", "status", []any{StatusReady, StatusRunning}).
+		And("run_at", "<=", now).
+		And("locked_until", "<=", now).
+		OrderBy("run_at", "ASC").
+		Limit(1)
+}
+
+// Complete removes a finished job from the queue. It returns ErrClaimLost if the job was claimed again after it's
+// visibility timeout, the job is left to that claim then.
+func (q *Queue) Complete(job Job) error {
+	return held(q.owned(q.db.Delete().Table(q.options.Table), job).Execute())
+}
+
+// Fail schedules the job again with backoff, or moves it to the dead state if it has reached the maximum attempts.
+// It returns ErrClaimLost just like Complete.
+func (q *Queue) Fail(job Job, cause error) error {
+	if job.Attempts >= q.options.MaxAttempts {
+		return q.kill(job, cause.Error())
+	}
+
+	update := q.db.Update().Table(q.options.Table).
+		Set("status", StatusReady).
+		Set("run_at", time.Now().Add(q.options.Backoff(job.Attempts)).UnixMilli()).
+		Set("locked_until", int64(0)).
+		Set("last_error", cause.Error())
+
+	return held(q.owned(update, job).Execute())
+}
+
+func (q *Queue) kill(job Job, cause string) error {
+	update := q.db.Update().Table(q.options.Table).
+		Set("status", StatusDead).
+		Set("locked_until", int64(0)).
+		Set("last_error", cause)
+
+	return held(q.owned(update, job).Execute())
+}
+
+// owned narrows a query to the claim of the job, every claim increments the attempts.
+func (q *Queue) owned(query neormgo.Query, job Job) neormgo.Query {
+	return query.Where("id", "=", job.ID).
+		And("attempts", "=", job.Attempts).
+		And("status", "=", StatusRunning)
+}
+
+// held turns a query that didn't change the job into ErrClaimLost.
+func held(result neormgo.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrClaimLost
+	}
+
+	return nil
+}
+
+// Dead returns the dead jobs of the queue, oldest first.
+func (q *Queue) Dead(ctx context.Context, queue string) ([]Job, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result, err := q.db.Select("*").Table(q.options.Table).
+		Where("queue", "=", queue).
+		And("status", "=", StatusDead).
+		OrderBy("id", "ASC").
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]Job, 0, len(result.Rows))
+
+	for _, row := range result.Rows {
+		job, err := decodeJob(row)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// Retry moves a dead job back to the queue with it's attempts reset.
+func (q *Queue) Retry(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := q.db.Update().Table(q.options.Table).
+		Set("status", StatusReady).
+		Set("attempts", 0).
+		Set("run_at", time.Now().UnixMilli()).
+		Set("locked_until", int64(0)).
+		Where("id", "=", id).
+		And("status", "=", StatusDead).
+		Execute()
+
+	return err
+}
+
+func decodeJob(row map[string]interface{}) (Job, error) {
+	job := Job{
+		ID:        toInt64(row["id"]),
+		Queue:     toString(row["queue"]),
+		Payload:   []byte(toString(row["payload"])),
+		Status:    toString(row["status"]),
+		Attempts:  int(toInt64(row["attempts"])),
+		RunAt:     time.UnixMilli(toInt64(row["run_at"])),
+		LastError: toString(row["last_error"]),
+		CreatedAt: time.UnixMilli(toInt64(row["created_at"])),
+	}
+
+	if job.ID == 0 {
+		return Job{}, fmt.Errorf("job row doesn't have an id: %v", row)
+	}
+
+	return job, nil
+}
+
+// toInt64 converts the integer columns, which are given back as different types by the drivers.
+func toInt64(value interface{}) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case int32:
+		return int64(v)
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	case []byte:
+		parsed, _ := strconv.ParseInt(string(v), 10, 64)
+		return parsed
+	case string:
+		parsed, _ := strconv.ParseInt(v, 10, 64)
+		return parsed
+	default:
+		return 0
+	}
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
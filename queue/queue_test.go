@@ -0,0 +1,168 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Necoo33/neormgo/v2"
+)
+
+func TestDefaultBackoff(t *testing.T) {
+	if DefaultBackoff(1) != time.Second || DefaultBackoff(3) != 4*time.Second || DefaultBackoff(40) != time.Hour {
+		t.Fatalf("Unexpected backoff: %s %s %s", DefaultBackoff(1), DefaultBackoff(3), DefaultBackoff(40))
+	}
+}
+
+func TestQueueOnSqlite(t *testing.T) {
+	db, err := neormgo.Open(filepath.Join(t.TempDir(), "queue.db")+"?_busy_timeout=5000", "sqlite3")
+	if err != nil {
+		t.Fatalf("Open failed: %s", err)
+	}
+	defer db.Close()
+
+	q := New(db, Options{MaxAttempts: 2, PollInterval: 10 * time.Millisecond, Backoff: func(int) time.Duration { return 0 }})
+	ctx := context.Background()
+
+	if err := q.CreateTable(ctx); err != nil {
+		t.Fatalf("CreateTable failed: %s", err)
+	}
+
+	failing, err := q.Enqueue(ctx, "mails", map[string]string{"to": "broken"}, time.Time{})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %s", err)
+	}
+
+	if _, err := q.Enqueue(ctx, "mails", map[string]string{"to": "john"}, time.Time{}); err != nil {
+		t.Fatalf("Enqueue failed: %s", err)
+	}
+
+	workCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var mu sync.Mutex
+	sent := map[string]int{}
+
+	handler := func(ctx context.Context, job Job) error {
+		var payload map[string]string
+		if err := job.Decode(&payload); err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		sent[payload["to"]]++
+
+		if payload["to"] == "broken" {
+			return errors.New("cannot send")
+		}
+
+		return nil
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := q.Work(workCtx, "mails", handler); err != nil {
+				t.Errorf("Work failed: %s", err)
+			}
+		}()
+	}
+
+	// the workers are stopped once both of the jobs are done:
+	for workCtx.Err() == nil {
+		mu.Lock()
+		done := sent["john"] == 1 && sent["broken"] == 2
+		mu.Unlock()
+
+		if done {
+			cancel()
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	wg.Wait()
+
+	if sent["john"] != 1 || sent["broken"] != 2 {
+		t.Fatalf("Unexpected runs of the jobs: %v", sent)
+	}
+
+	dead, err := q.Dead(ctx, "mails")
+	if err != nil {
+		t.Fatalf("Dead failed: %s", err)
+	}
+
+	if len(dead) != 1 || dead[0].ID != failing || dead[0].LastError != "cannot send" || dead[0].Attempts != 2 {
+		t.Fatalf("Unexpected dead jobs: %+v", dead)
+	}
+
+	if err := q.Retry(ctx, failing); err != nil {
+		t.Fatalf("Retry failed: %s", err)
+	}
+
+	job, err := q.Claim(ctx, "mails")
+	if err != nil || job == nil || job.ID != failing || job.Attempts != 1 {
+		t.Fatalf("Retried job should be claimed again: %+v %v", job, err)
+	}
+
+	if again, err := q.Claim(ctx, "mails"); err != nil || again != nil {
+		t.Fatalf("A claimed job shouldn't be claimed before it's visibility timeout: %+v %v", again, err)
+	}
+}
+
+func TestClaimLost(t *testing.T) {
+	db, err := neormgo.Open(filepath.Join(t.TempDir(), "queue.db")+"?_busy_timeout=5000", "sqlite3")
+	if err != nil {
+		t.Fatalf("Open failed: %s", err)
+	}
+	defer db.Close()
+
+	q := New(db, Options{VisibilityTimeout: 50 * time.Millisecond})
+	ctx := context.Background()
+
+	if err := q.CreateTable(ctx); err != nil {
+		t.Fatalf("CreateTable failed: %s", err)
+	}
+
+	if _, err := q.Enqueue(ctx, "reports", "daily", time.Time{}); err != nil {
+		t.Fatalf("Enqueue failed: %s", err)
+	}
+
+	first, err := q.Claim(ctx, "reports")
+	if err != nil || first == nil {
+		t.Fatalf("Claim failed: %+v %v", first, err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	second, err := q.Claim(ctx, "reports")
+	if err != nil || second == nil || second.ID != first.ID {
+		t.Fatalf("A timed out job should be claimed again: %+v %v", second, err)
+	}
+
+	if err := q.Complete(*first); !errors.Is(err, ErrClaimLost) {
+		t.Fatalf("Completing a job that is claimed again should fail: %v", err)
+	}
+
+	if err := q.Fail(*first, errors.New("late")); !errors.Is(err, ErrClaimLost) {
+		t.Fatalf("Failing a job that is claimed again should fail: %v", err)
+	}
+
+	if err := q.Complete(*second); err != nil {
+		t.Fatalf("Complete failed: %s", err)
+	}
+
+	if err := q.Complete(*second); !errors.Is(err, ErrClaimLost) {
+		t.Fatalf("A completed job shouldn't be completed again: %v", err)
+	}
+}
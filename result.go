@@ -0,0 +1,67 @@
+package neormgo
+
+import (
+	"fmt"
+	"time"
+)
+
+// execution results:
+
+// Column is a column of the rows that a query gave back.
+type Column struct {
+	Name         string
+	DatabaseType string
+	Nullable     bool
+}
+
+// Result is what an executed query gives back, it doesn't depend on the builder or the connection, so the builder
+// can be reused right after. Rows are the selected rows, Returned are the rows given back by Returning, LastInsertId
+// is empty and RowsAffected is zero for the queries that don't have them. Columns are not kept for cached rows.
+type Result struct {
+	Rows         []map[string]interface{}
+	Columns      []Column
+	RowsAffected int64
+	LastInsertId string
+	Returned     []map[string]interface{}
+	Length       int64
+	Duration     time.Duration
+}
+
+// ExecuteResult executes the query just like Execute and gives back it's outcome as a Result.
+func (orm *Neorm) ExecuteResult() (Result, error) {
+	if err := orm.Execute(); err != nil {
+		return Result{Duration: orm._Duration}, err
+	}
+
+	return orm.result(), nil
+}
+
+func (orm *Neorm) result() Result {
+	result := Result{
+		Columns:      orm._Columns,
+		Length:       orm._Count,
+		LastInsertId: orm._LastInsertIdForPostgresql,
+		Duration:     orm._Duration,
+	}
+
+	if orm._ReturnsRows {
+		result.Returned = orm._Rows
+		result.RowsAffected = int64(len(orm._Rows))
+	} else {
+		result.Rows = orm._Rows
+	}
+
+	if orm._Result != nil {
+		if ra, err := orm._Result.RowsAffected(); err == nil {
+			result.RowsAffected = ra
+		}
+
+		if orm._Driver != Postgresql && orm._Driver != MicrosoftSqlServer {
+			if lid, err := orm._Result.LastInsertId(); err == nil {
+				result.LastInsertId = fmt.Sprintf("%d", lid)
+			}
+		}
+	}
+
+	return result
+}
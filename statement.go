@@ -0,0 +1,741 @@
+package neormgo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// query representation:
+
+// fragment is a piece of sql with it's bound values. The values go between the texts, so texts always has one more
+// element than args. Placeholders are written when the statement is rendered, with the syntax of the driver.
+type fragment struct {
+	texts []string
+	args  []any
+}
+
+func raw(text string) fragment {
+	return fragment{texts: []string{text}}
+}
+
+func bound(value any) fragment {
+	return fragment{texts: []string{"", ""}, args: []any{value}}
+}
+
+// concat joins strings and fragments in the given order.
+func concat(parts ...interface{}) fragment {
+	result := fragment{texts: []string{""}}
+
+	for _, part := range parts {
+		switch t := part.(type) {
+		case string:
+			result.texts[len(result.texts)-1] += t
+		case fragment:
+			if len(t.texts) == 0 {
+				continue
+			}
+
+			result.texts[len(result.texts)-1] += t.texts[0]
+			result.texts = append(result.texts, t.texts[1:]...)
+			result.args = append(result.args, t.args...)
+		default:
+			panic(fmt.Sprintf("Unexpected part of a fragment: %T", part))
+		}
+	}
+
+	return result
+}
+
+func joinFragments(fragments []fragment, separator string) fragment {
+	parts := make([]interface{}, 0, len(fragments)*2)
+
+	for i, f := range fragments {
+		if i != 0 {
+			parts = append(parts, separator)
+		}
+
+		parts = append(parts, f)
+	}
+
+	return concat(parts...)
+}
+
+func (f fragment) isEmpty() bool {
+	return len(f.args) == 0 && strings.Join(f.texts, "") == ""
+}
+
+// condition is a WHERE, AND or OR condition, or an opened or closed parenthesis. A condition that matches the table
+// is a sqlite full-text search, the table name is written before it's expression when it's rendered.
+type condition struct {
+	connector    string
+	open         bool
+	close        bool
+	matchesTable bool
+	expr         fragment
+}
+
+type joinClause struct {
+	kind  string
+	table string
+	on    string
+}
+
+// definition is a column or a constraint of a created table, or an action of an altered one. Modifiers like
+// NotNull or After are appended to the parts of the last definition.
+type definition struct {
+	text     string
+	dataType string
+	parts    []string
+	column   bool
+}
+
+type statement struct {
+	kind          string
+	table         string
+	source        fragment
+	raw           fragment
+	columns       []fragment
+	insertColumns []string
+	values        []fragment
+	set           []fragment
+	joins         []joinClause
+	conditions    []condition
+	groupBy       []fragment
+	orderBy       []fragment
+	limit         int
+	offset        int
+	hasLimit      bool
+	hasOffset     bool
+	returning     []string
+	lock          string
+	lockWait      string
+	lockOf        []string
+	into          string
+	ifNotExists   bool
+	definitions   []definition
+	options       []string
+	tags          []string
+	invalidates   []string
+}
+
+func (st statement) clone() statement {
+	copied := st
+
+	copied.columns = append([]fragment(nil), st.columns...)
+	copied.insertColumns = append([]string(nil), st.insertColumns...)
+	copied.values = append([]fragment(nil), st.values...)
+	copied.set = append([]fragment(nil), st.set...)
+	copied.joins = append([]joinClause(nil), st.joins...)
+	copied.conditions = append([]condition(nil), st.conditions...)
+	copied.groupBy = append([]fragment(nil), st.groupBy...)
+	copied.orderBy = append([]fragment(nil), st.orderBy...)
+	copied.returning = append([]string(nil), st.returning...)
+	copied.lockOf = append([]string(nil), st.lockOf...)
+	copied.options = append([]string(nil), st.options...)
+	copied.tags = append([]string(nil), st.tags...)
+	copied.invalidates = append([]string(nil), st.invalidates...)
+	copied.definitions = make([]definition, len(st.definitions))
+
+	for i, d := range st.definitions {
+		d.parts = append([]string(nil), d.parts...)
+		copied.definitions[i] = d
+	}
+
+	return copied
+}
+
+// rendering:
+
+// render writes the statement into Query and it's values into _Args, with the syntax of the driver.
+func (orm *Neorm) render() {
+	st := &orm._Statement
+	orm._Args = []any{}
+	orm._Pending = false
+
+	query := orm.renderStatement()
+
+	var b strings.Builder
+
+	for i, text := range query.texts {
+		b.WriteString(text)
+
+		if i < len(query.args) {
+			orm._Args = append(orm._Args, query.args[i])
+			b.WriteString(orm.getPlaceHolder())
+		}
+	}
+
+	orm.Query = b.String()
+
+	orm._ReturnsRows = len(st.returning) > 0
+}
+
+// renderStatement gives the statement as a fragment, without writing the placeholders.
+func (orm *Neorm) renderStatement() fragment {
+	st := &orm._Statement
+
+	var query fragment
+
+	switch st.kind {
+	case "select":
+		query = orm.renderSelect()
+	case "count":
+		query = concat("SELECT COUNT(*) AS length FROM ", st.table, orm.renderJoins(), orm.renderConditions())
+	case "insert":
+		query = orm.renderInsert()
+	case "update":
+		query = orm.renderUpdate()
+	case "delete":
+		query = orm.renderDelete()
+	case "createSchema":
+		if st.ifNotExists {
+			query = raw(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", st.table))
+		} else {
+			query = raw(fmt.Sprintf("CREATE DATABASE %s", st.table))
+		}
+	case "createTable":
+		query = raw(orm.renderCreateTable())
+	case "alterTable":
+		actions := make([]string, len(st.definitions))
+		for i, d := range st.definitions {
+			actions[i] = d.render()
+		}
+
+		query = raw(strings.TrimSpace(fmt.Sprintf("ALTER TABLE %s %s", st.table, strings.Join(actions, ", "))))
+	case "insertSelect":
+		query = orm.renderInsert()
+	case "createTableAs":
+		// microsoft sql server's SELECT INTO is rendered by the select itself:
+		if orm._Driver == MicrosoftSqlServer {
+			query = st.source
+		} else {
+			query = concat("CREATE TABLE ", st.table, " AS ", st.source)
+		}
+	case "raw":
+		query = orm.renderRaw()
+	default:
+		query = st.raw
+	}
+
+	return query
+}
+
+func (orm *Neorm) renderSelect() fragment {
+	st := &orm._Statement
+
+	head := "SELECT "
+	if orm._Driver == MicrosoftSqlServer && st.hasLimit && !st.hasOffset {
+		head = fmt.Sprintf("SELECT TOP (%d) ", st.limit)
+	}
+
+	columns := raw("*")
+	if len(st.columns) != 0 {
+		columns = joinFragments(st.columns, ", ")
+	}
+
+	if st.into != "" {
+		columns = concat(columns, " INTO ", st.into)
+	}
+
+	from := raw("")
+	if !st.source.isEmpty() {
+		from = concat(" FROM ", st.source)
+	} else if st.table != "" {
+		from = raw(" FROM " + st.table + orm.renderTableHint(st.table))
+	}
+
+	return concat(head, columns, from, orm.renderJoins(), orm.renderConditions(), orm.renderGroupBy(), orm.renderOrderBy(), orm.renderPagination(), orm.renderLock())
+}
+
+// renderLock writes the row locking clause of postgresql and mysql, microsoft sql server uses table hints instead
+// and sqlite doesn't have row locks.
+func (orm *Neorm) renderLock() string {
+	st := &orm._Statement
+
+	if st.lock == "" {
+		if st.lockWait != "" || len(st.lockOf) != 0 {
+			panic("Error on row locking: ForUpdate or ForShare should be called with SkipLocked, NoWait and Of.")
+		}
+
+		return ""
+	}
+
+	if orm._Driver != Postgresql && orm._Driver != Mysql {
+		return ""
+	}
+
+	lock := " FOR " + st.lock
+
+	if len(st.lockOf) != 0 {
+		lock = fmt.Sprintf("%s OF %s", lock, strings.Join(st.lockOf, ", "))
+	}
+
+	if st.lockWait != "" {
+		lock = fmt.Sprintf("%s %s", lock, st.lockWait)
+	}
+
+	return lock
+}
+
+// renderTableHint gives the locking hint of a table on microsoft sql server, all of the tables of the query
+// are locked unless Of is used.
+func (orm *Neorm) renderTableHint(table string) string {
+	st := &orm._Statement
+
+	if orm._Driver != MicrosoftSqlServer || st.lock == "" {
+		return ""
+	}
+
+	if len(st.lockOf) != 0 {
+		found := false
+
+		for _, of := range st.lockOf {
+			if of == table {
+				found = true
+			}
+		}
+
+		if !found {
+			return ""
+		}
+	}
+
+	hints := []string{"UPDLOCK", "ROWLOCK"}
+	if st.lock == "SHARE" {
+		hints = []string{"HOLDLOCK", "ROWLOCK"}
+	}
+
+	switch st.lockWait {
+	case "SKIP LOCKED":
+		hints = append(hints, "READPAST")
+	case "NOWAIT":
+		hints = append(hints, "NOWAIT")
+	}
+
+	return fmt.Sprintf(" WITH (%s)", strings.Join(hints, ", "))
+}
+
+func (orm *Neorm) renderInsert() fragment {
+	st := &orm._Statement
+
+	output := ""
+	returning := ""
+
+	if len(st.returning) != 0 {
+		if orm._Driver == MicrosoftSqlServer {
+			output = " " + orm.renderOutput("INSERTED")
+		} else {
+			returning = " RETURNING " + strings.Join(st.returning, ", ")
+		}
+	}
+
+	columns := ""
+	if len(st.insertColumns) != 0 {
+		columns = fmt.Sprintf(" (%s)", strings.Join(st.insertColumns, ", "))
+	}
+
+	if st.kind == "insertSelect" {
+		return concat("INSERT INTO ", st.table, columns, output, " ", st.source, returning)
+	}
+
+	return concat("INSERT INTO ", st.table, columns, output, " VALUES (", joinFragments(st.values, ", "), ")", returning)
+}
+
+func (orm *Neorm) renderUpdate() fragment {
+	st := &orm._Statement
+
+	head := "UPDATE "
+	if orm._Driver == MicrosoftSqlServer && st.hasLimit {
+		head = fmt.Sprintf("UPDATE TOP (%d) ", st.limit)
+	}
+
+	output := ""
+	returning := ""
+
+	if len(st.returning) != 0 {
+		if orm._Driver == MicrosoftSqlServer {
+			output = " " + orm.renderOutput("INSERTED")
+		} else {
+			returning = " RETURNING " + strings.Join(st.returning, ", ")
+		}
+	}
+
+	set := joinFragments(st.set, ", ")
+
+	if len(st.joins) != 0 {
+		switch orm._Driver {
+		case Postgresql, Sqlite3:
+			from, conditions := orm.renderJoinedFrom("Update")
+
+			return concat(head, st.table, " SET ", set, " FROM ", from, conditions, orm.renderOrderBy(), orm.renderPagination(), returning)
+		case MicrosoftSqlServer:
+			return concat(head, st.table, " SET ", set, output, " FROM ", st.table, orm.renderJoins(), orm.renderConditions(), orm.renderOrderBy(),
+				orm.renderPagination())
+		}
+	}
+
+	// the limit of microsoft sql server is in the head, renderPagination still rejects the offset:
+	return concat(head, st.table, orm.renderJoins(), " SET ", set, output, orm.renderConditions(), orm.renderOrderBy(), orm.renderPagination(), returning)
+}
+
+func (orm *Neorm) renderDelete() fragment {
+	st := &orm._Statement
+
+	head := "DELETE FROM "
+	if orm._Driver == MicrosoftSqlServer && st.hasLimit {
+		head = fmt.Sprintf("DELETE TOP (%d) FROM ", st.limit)
+	}
+
+	output := ""
+	returning := ""
+
+	if len(st.returning) != 0 {
+		if orm._Driver == MicrosoftSqlServer {
+			output = " " + orm.renderOutput("DELETED")
+		} else {
+			returning = " RETURNING " + strings.Join(st.returning, ", ")
+		}
+	}
+
+	if len(st.joins) != 0 {
+		switch orm._Driver {
+		case Postgresql:
+			using, conditions := orm.renderJoinedFrom("Delete")
+
+			return concat("DELETE FROM ", st.table, " USING ", using, conditions, orm.renderPagination(), returning)
+		case Sqlite3:
+			// sqlite doesn't have a join syntax for delete, the rows are found with a subquery:
+			return concat("DELETE FROM ", st.table, " WHERE rowid IN (SELECT ", st.table, ".rowid FROM ", st.table, orm.renderJoins(),
+				orm.renderConditions(), orm.renderOrderBy(), orm.renderPagination(), ")", returning)
+		case MicrosoftSqlServer:
+			return concat(strings.TrimSuffix(head, "FROM "), st.table, output, " FROM ", st.table, orm.renderJoins(), orm.renderConditions(),
+				orm.renderOrderBy(), orm.renderPagination())
+		default:
+			// the multiple table syntax of mysql doesn't take an ordering, a limit or a returning clause:
+			if returning != "" {
+				panic("Returning is not supported on delete queries with joins for mysql.")
+			}
+
+			return concat("DELETE ", st.table, " FROM ", st.table, orm.renderJoins(), orm.renderConditions(), orm.renderOrderBy(), orm.renderPagination())
+		}
+	}
+
+	return concat(head, st.table, orm.renderJoins(), output, orm.renderConditions(), orm.renderOrderBy(), orm.renderPagination(), returning)
+}
+
+// renderJoinedFrom writes the joins of an update or delete for postgresql and sqlite, which take the joined tables in
+// their FROM (or USING) clause: the first joined table goes there and it's join condition is moved to the WHERE clause.
+func (orm *Neorm) renderJoinedFrom(method string) (string, fragment) {
+	st := &orm._Statement
+	first := st.joins[0]
+
+	if first.kind != "INNER JOIN" && first.kind != "CROSS JOIN" {
+		panic(fmt.Sprintf("Error on %s method: the first join should be an inner or cross join on postgresql and sqlite.", method))
+	}
+
+	from := first.table + orm.renderJoinList(st.joins[1:])
+	conditions := orm.renderConditions()
+
+	if first.on == "" {
+		return from, conditions
+	}
+
+	if conditions.isEmpty() {
+		return from, raw(" WHERE " + first.on)
+	}
+
+	conditions.texts[0] = strings.TrimPrefix(conditions.texts[0], " WHERE ")
+
+	return from, concat(" WHERE ", first.on, " AND (", conditions, ")")
+}
+
+// renderRaw renders custom queries with the clauses that are added to them.
+func (orm *Neorm) renderRaw() fragment {
+	st := &orm._Statement
+
+	query := concat(st.raw, orm.renderJoins(), orm.renderConditions(), orm.renderGroupBy(), orm.renderOrderBy())
+
+	if orm._Driver == MicrosoftSqlServer && st.hasLimit && !st.hasOffset {
+		head := query.texts[0]
+		top := fmt.Sprintf("TOP (%d)", st.limit)
+
+		switch {
+		case strings.HasPrefix(head, "SELECT DISTINCT "):
+			head = fmt.Sprintf("SELECT DISTINCT %s %s", top, strings.TrimPrefix(head, "SELECT DISTINCT "))
+		case strings.HasPrefix(head, "SELECT "):
+			head = fmt.Sprintf("SELECT %s %s", top, strings.TrimPrefix(head, "SELECT "))
+		case strings.HasPrefix(head, "UPDATE "):
+			head = fmt.Sprintf("UPDATE %s %s", top, strings.TrimPrefix(head, "UPDATE "))
+		case strings.HasPrefix(head, "DELETE FROM "):
+			head = fmt.Sprintf("DELETE %s FROM %s", top, strings.TrimPrefix(head, "DELETE FROM "))
+		default:
+			panic("Limit is only supported on select, update and delete queries for microsoft sql server.")
+		}
+
+		query.texts[0] = head
+	} else {
+		query = concat(query, orm.renderPagination())
+	}
+
+	if len(st.returning) == 0 {
+		return query
+	}
+
+	if orm._Driver != MicrosoftSqlServer {
+		return concat(query, " RETURNING ", strings.Join(st.returning, ", "))
+	}
+
+	// the output clause of a custom query is put before it's VALUES, SELECT or WHERE part:
+	output := orm.renderOutput("INSERTED")
+	keywords := []string{" VALUES ", " VALUES(", " SELECT ", " DEFAULT VALUES", " WHERE "}
+
+	if strings.HasPrefix(strings.ToUpper(query.texts[0]), "DELETE") {
+		output = orm.renderOutput("DELETED")
+	}
+
+	for i, text := range query.texts {
+		for _, keyword := range keywords {
+			if index := strings.Index(strings.ToUpper(text), keyword); index != -1 {
+				query.texts[i] = fmt.Sprintf("%s %s%s", text[:index], output, text[index:])
+
+				return query
+			}
+		}
+	}
+
+	return concat(query, " ", output)
+}
+
+func (orm *Neorm) renderOutput(prefix string) string {
+	output := "OUTPUT"
+
+	for i, column := range orm._Statement.returning {
+		if i != 0 {
+			output = output + ","
+		}
+
+		output = fmt.Sprintf("%s %s.%s", output, prefix, column)
+	}
+
+	return output
+}
+
+func (orm *Neorm) renderJoins() string {
+	return orm.renderJoinList(orm._Statement.joins)
+}
+
+func (orm *Neorm) renderJoinList(joinClauses []joinClause) string {
+	joins := ""
+
+	for _, join := range joinClauses {
+		table := join.table
+		if orm._Statement.kind == "select" {
+			table = table + orm.renderTableHint(join.table)
+		}
+
+		if join.on == "" {
+			joins = fmt.Sprintf("%s %s %s", joins, join.kind, table)
+		} else {
+			joins = fmt.Sprintf("%s %s %s ON %s", joins, join.kind, table, join.on)
+		}
+	}
+
+	return joins
+}
+
+// renderConditions writes the first condition with WHERE and the ones after with their own connectors,
+// a condition right after an opened parenthesis is written without any.
+func (orm *Neorm) renderConditions() fragment {
+	st := &orm._Statement
+	result := raw("")
+	afterOpen := false
+
+	// a custom query may already have it's own WHERE clause:
+	started := st.kind == "raw" && hasKeyword(strings.Join(st.raw.texts, ""), "WHERE", orm._Driver)
+
+	for _, c := range st.conditions {
+		if c.close {
+			result = concat(result, ")")
+			afterOpen = false
+
+			continue
+		}
+
+		connector := c.connector
+		if !started {
+			connector = "WHERE"
+		} else if connector == "WHERE" {
+			connector = "AND"
+		}
+
+		started = true
+
+		if c.matchesTable {
+			if st.table == "" {
+				panic("Error on Match method: Table should be called on sqlite.")
+			}
+
+			c.expr = concat(st.table, " MATCH ", c.expr)
+		}
+
+		if c.open {
+			result = concat(result, " ", connector, " (")
+			afterOpen = true
+
+			continue
+		}
+
+		if afterOpen {
+			result = concat(result, c.expr)
+		} else {
+			result = concat(result, " ", connector, " ", c.expr)
+		}
+
+		afterOpen = false
+	}
+
+	return result
+}
+
+func (orm *Neorm) renderGroupBy() fragment {
+	if len(orm._Statement.groupBy) == 0 {
+		return raw("")
+	}
+
+	return concat(" GROUP BY ", joinFragments(orm._Statement.groupBy, ", "))
+}
+
+func (orm *Neorm) renderOrderBy() fragment {
+	st := &orm._Statement
+
+	// postgresql and microsoft sql server don't have an ORDER BY for update and delete, mysql doesn't have it for the
+	// ones with joins:
+	if len(st.orderBy) != 0 && (st.kind == "update" || st.kind == "delete") {
+		switch {
+		case orm._Driver == Postgresql || orm._Driver == MicrosoftSqlServer:
+			panic("OrderBy is not supported on update and delete queries for postgresql and microsoft sql server.")
+		case orm._Driver == Mysql && len(st.joins) != 0:
+			panic("OrderBy is not supported on update and delete queries with joins for mysql.")
+		}
+	}
+
+	// a custom query may already have it's own ORDER BY clause, the orderings are added after it's own:
+	ordered := st.kind == "raw" && hasKeyword(strings.Join(st.raw.texts, ""), "ORDER BY", orm._Driver)
+
+	if len(st.orderBy) == 0 {
+		// microsoft sql server requires an ORDER BY for OFFSET:
+		if orm._Driver == MicrosoftSqlServer && st.hasOffset && (st.kind == "select" || st.kind == "raw") && !ordered {
+			return raw(" ORDER BY (SELECT NULL)")
+		}
+
+		return raw("")
+	}
+
+	if ordered {
+		return concat(", ", joinFragments(st.orderBy, ", "))
+	}
+
+	return concat(" ORDER BY ", joinFragments(st.orderBy, ", "))
+}
+
+func (orm *Neorm) renderPagination() string {
+	st := &orm._Statement
+
+	if !st.hasLimit && !st.hasOffset {
+		return ""
+	}
+
+	if st.hasOffset && st.kind != "select" && st.kind != "raw" {
+		panic("Offset is only supported on select queries.")
+	}
+
+	// postgresql doesn't have a LIMIT for update and delete, mysql doesn't have it for the ones with joins:
+	if st.hasLimit && orm._Driver == Postgresql && (st.kind == "update" || st.kind == "delete") {
+		panic("Limit is not supported on update and delete queries for postgresql.")
+	}
+
+	if st.hasLimit && orm._Driver == Mysql && len(st.joins) != 0 && (st.kind == "update" || st.kind == "delete") {
+		panic("Limit is not supported on update and delete queries with joins for mysql.")
+	}
+
+	pagination := ""
+
+	switch orm._Driver {
+	case MicrosoftSqlServer:
+		// a limit without offset is rendered as TOP:
+		if st.hasOffset {
+			pagination = fmt.Sprintf(" OFFSET %d ROWS", st.offset)
+
+			if st.hasLimit {
+				pagination = fmt.Sprintf("%s FETCH NEXT %d ROWS ONLY", pagination, st.limit)
+			}
+		}
+	case Mysql:
+		if st.hasLimit {
+			pagination = fmt.Sprintf(" LIMIT %d", st.limit)
+		} else {
+			// mysql doesn't accept an OFFSET without LIMIT, that's the documented way to skip rows:
+			pagination = " LIMIT 18446744073709551615"
+		}
+
+		if st.hasOffset {
+			pagination = fmt.Sprintf("%s OFFSET %d", pagination, st.offset)
+		}
+	case Sqlite3:
+		if st.hasLimit {
+			pagination = fmt.Sprintf(" LIMIT %d", st.limit)
+		} else {
+			pagination = " LIMIT -1"
+		}
+
+		if st.hasOffset {
+			pagination = fmt.Sprintf("%s OFFSET %d", pagination, st.offset)
+		}
+	default:
+		if st.hasLimit {
+			pagination = fmt.Sprintf(" LIMIT %d", st.limit)
+		}
+
+		if st.hasOffset {
+			pagination = fmt.Sprintf("%s OFFSET %d", pagination, st.offset)
+		}
+	}
+
+	return pagination
+}
+
+func (orm *Neorm) renderCreateTable() string {
+	st := &orm._Statement
+
+	query := "CREATE TABLE "
+	if st.ifNotExists {
+		query = "CREATE TABLE IF NOT EXISTS "
+	}
+
+	definitions := make([]string, len(st.definitions))
+	for i, d := range st.definitions {
+		definitions[i] = d.render()
+	}
+
+	query = fmt.Sprintf("%s%s (%s)", query, st.table, strings.Join(definitions, ", "))
+
+	for _, option := range st.options {
+		query = fmt.Sprintf("%s %s", query, option)
+	}
+
+	return query
+}
+
+func (d definition) render() string {
+	rendered := d.text
+
+	if d.dataType != "" {
+		rendered = fmt.Sprintf("%s %s", rendered, d.dataType)
+	}
+
+	for _, part := range d.parts {
+		rendered = fmt.Sprintf("%s %s", rendered, part)
+	}
+
+	return rendered
+}